@@ -0,0 +1,303 @@
+package internal
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/oasdiff/oasdiff/load"
+	log "github.com/sirupsen/logrus"
+)
+
+// entrypoints looked up at the archive root when no entrypoint parameter is given
+var defaultEntrypoints = []string{"openapi.yaml", "openapi.yml", "openapi.json"}
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// isArchive tells whether data is a zip or a gzipped tar archive
+func isArchive(data []byte) bool {
+	return bytes.HasPrefix(data, zipMagic) || bytes.HasPrefix(data, gzipMagic)
+}
+
+// getEntrypoint returns the entrypoint of the input's archive: the '<input>-entrypoint' parameter or else the 'entrypoint' parameter
+func getEntrypoint(r *http.Request, input string) string {
+	return GetQueryString(r, input+"-entrypoint", GetQueryString(r, "entrypoint", ""))
+}
+
+// archiveSource is a multi-file spec uploaded as a zip or tar.gz archive.
+// The archive is extracted into a sandboxed temporary directory: relative refs resolve inside it and it is removed once the spec is loaded.
+type archiveSource struct {
+	limits     Limits
+	input      string
+	name       string // the name of the spec in reports if the entrypoint isn't a glob, see dataSource
+	entrypoint string
+	data       []byte
+}
+
+func (s archiveSource) Input() string { return s.input }
+
+func (s archiveSource) Load(fetcher *Fetcher, options ...load.Option) ([]*load.SpecInfo, error) {
+
+	dir, err := os.MkdirTemp("", "oasdiff-archive-")
+	if err != nil {
+		return nil, newServerProblem(fmt.Sprintf("failed to create archive directory with %v", err))
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("failed to remove archive directory '%s' with %v", dir, err)
+		}
+	}()
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, newServerProblem(fmt.Sprintf("failed to open archive directory with %v", err))
+	}
+	defer root.Close()
+
+	if err := extractArchive(root, s.data, s.limits); err != nil {
+		return nil, withProblemInput(err, s.input)
+	}
+
+	entrypoints, err := findEntrypoints(root, s.entrypoint, s.input)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = sandboxReader{root: root, dir: dir, fetcher: fetcher}.ReadFromURI
+
+	res := make([]*load.SpecInfo, len(entrypoints))
+	for i, entrypoint := range entrypoints {
+		spec, err := load.NewSpecInfo(loader, load.NewSource(filepath.Join(dir, entrypoint)), options...)
+		if err != nil {
+			problem := newSpecLoadProblem(err, s.input)
+			// don't expose the server's directory layout
+			problem.Detail = strings.ReplaceAll(problem.Detail, dir+string(filepath.Separator), "")
+			return nil, problem
+		}
+		spec.Url = s.name
+		if len(entrypoints) > 1 {
+			spec.Url = filepath.ToSlash(entrypoint)
+		}
+		res[i] = spec
+	}
+
+	return res, nil
+}
+
+func withProblemInput(err error, input string) error {
+
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem.WithInput(input)
+	}
+
+	return err
+}
+
+// findEntrypoints returns the files inside the archive matching the entrypoint, which may be a glob to compose several specs.
+// An empty entrypoint defaults to a well-known name at the archive root.
+func findEntrypoints(root *os.Root, entrypoint string, input string) ([]string, error) {
+
+	if entrypoint == "" {
+		for _, name := range defaultEntrypoints {
+			if info, err := root.Stat(name); err == nil && info.Mode().IsRegular() {
+				return []string{name}, nil
+			}
+		}
+		return nil, NewProblem(http.StatusBadRequest, ProblemTypeMissingParameter,
+			fmt.Sprintf("no entrypoint given for the '%s' archive and none of '%s' at its root", input, strings.Join(defaultEntrypoints, "', '"))).WithInput("entrypoint")
+	}
+
+	pattern := path.Clean(filepath.ToSlash(entrypoint))
+	if !fs.ValidPath(pattern) {
+		return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("entrypoint '%s' must be a relative path inside the archive", entrypoint)).WithInput("entrypoint")
+	}
+
+	matches, err := fs.Glob(root.FS(), pattern)
+	if err != nil {
+		return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("invalid entrypoint '%s' with %v", entrypoint, err)).WithInput("entrypoint")
+	}
+
+	var res []string
+	for _, match := range matches {
+		if info, err := root.Stat(filepath.FromSlash(match)); err == nil && info.Mode().IsRegular() {
+			res = append(res, filepath.FromSlash(match))
+		}
+	}
+	if len(res) == 0 {
+		return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("entrypoint '%s' not found in the '%s' archive", entrypoint, input)).WithInput("entrypoint")
+	}
+
+	return res, nil
+}
+
+// extractArchive extracts the regular files of a zip or tar.gz archive, other entries such as symlinks are skipped
+func extractArchive(root *os.Root, data []byte, limits Limits) error {
+
+	extractor := archiveExtractor{root: root, limits: limits}
+
+	if bytes.HasPrefix(data, zipMagic) {
+		return extractor.zip(data)
+	}
+
+	return extractor.tarGz(data)
+}
+
+type archiveExtractor struct {
+	root   *os.Root
+	limits Limits
+	files  int
+	size   int64
+}
+
+func (e *archiveExtractor) zip(data []byte) error {
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return newArchiveProblem(err)
+	}
+
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if err := e.extractZipFile(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *archiveExtractor) extractZipFile(file *zip.File) error {
+
+	reader, err := file.Open()
+	if err != nil {
+		return newArchiveProblem(err)
+	}
+	defer reader.Close()
+
+	return e.extract(file.Name, reader)
+}
+
+func (e *archiveExtractor) tarGz(data []byte) error {
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return newArchiveProblem(err)
+	}
+	defer gzipReader.Close()
+
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return newArchiveProblem(err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := e.extract(header.Name, reader); err != nil {
+			return err
+		}
+	}
+}
+
+// extract writes a single file into the root, within the archive limits
+func (e *archiveExtractor) extract(name string, reader io.Reader) error {
+
+	name = filepath.FromSlash(path.Clean(name))
+	if !filepath.IsLocal(name) {
+		return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("archive entry '%s' is outside of the archive", name))
+	}
+
+	e.files++
+	if e.files > e.limits.MaxArchiveFiles {
+		return NewProblem(http.StatusRequestEntityTooLarge, ProblemTypePayloadTooLarge, fmt.Sprintf("archive has more than %d files", e.limits.MaxArchiveFiles))
+	}
+
+	// read one extra byte to detect archives exceeding the limit
+	remaining := e.limits.MaxArchiveSize - e.size
+	data, err := io.ReadAll(io.LimitReader(reader, remaining+1))
+	if err != nil {
+		return newArchiveProblem(err)
+	}
+	e.size += int64(len(data))
+	if e.size > e.limits.MaxArchiveSize {
+		return NewProblem(http.StatusRequestEntityTooLarge, ProblemTypePayloadTooLarge, fmt.Sprintf("extracted archive exceeds %d bytes", e.limits.MaxArchiveSize))
+	}
+
+	if dir := filepath.Dir(name); dir != "." {
+		if err := e.root.MkdirAll(dir, 0o700); err != nil {
+			return newArchiveWriteProblem(name, err)
+		}
+	}
+	if err := e.root.WriteFile(name, data, 0o600); err != nil {
+		return newArchiveWriteProblem(name, err)
+	}
+
+	return nil
+}
+
+func newArchiveProblem(err error) *Problem {
+	return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("failed to read archive with %v", err))
+}
+
+// newArchiveWriteProblem classifies a failure to write an archive entry, entries conflicting with each other are bad input (400)
+func newArchiveWriteProblem(name string, err error) *Problem {
+
+	if errors.Is(err, fs.ErrExist) || errors.Is(err, syscall.ENOTDIR) || errors.Is(err, syscall.EISDIR) {
+		return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("failed to extract archive entry '%s'", name))
+	}
+
+	return newServerProblem(fmt.Sprintf("failed to extract archive entry '%s' with %v", name, err))
+}
+
+// sandboxReader reads refs of an extracted archive: local refs may only read files inside the archive directory and remote refs are fetched with the fetcher
+type sandboxReader struct {
+	root    *os.Root
+	dir     string
+	fetcher *Fetcher
+}
+
+// ReadFromURI implements openapi3.ReadFromURIFunc
+func (r sandboxReader) ReadFromURI(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+
+	if location.Scheme != "" || location.Host != "" {
+		return r.fetcher.ReadFromURI(loader, location)
+	}
+
+	name, err := filepath.Rel(r.dir, filepath.FromSlash(location.Path))
+	if err != nil || !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("ref '%s' is outside of the archive", location.Path)
+	}
+
+	data, err := r.root.ReadFile(name)
+	if err != nil {
+		// don't wrap the error, failing to read a ref is a problem with the archive
+		return nil, fmt.Errorf("ref '%s' not found in the archive", filepath.ToSlash(name))
+	}
+
+	return data, nil
+}
@@ -0,0 +1,144 @@
+package internal_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+const archiveSpec = `openapi: 3.0.0
+info:
+  title: archive
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: 'schemas/user.yaml'
+`
+
+func createZip(t *testing.T, files map[string]string) []byte {
+
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = file.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	return buf.Bytes()
+}
+
+func createTarGz(t *testing.T, files map[string]string) []byte {
+
+	buf := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buf)
+	writer := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := writer.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, gzipWriter.Close())
+
+	return buf.Bytes()
+}
+
+func TestChangelogFromFile_Zip(t *testing.T) {
+
+	base := createZip(t, map[string]string{"openapi.yaml": archiveSpec, "schemas/user.yaml": "type: object\nproperties:\n  name:\n    type: string\n"})
+	revision := createZip(t, map[string]string{"openapi.yaml": archiveSpec, "schemas/user.yaml": "type: object\nproperties:\n  name:\n    type: integer\n"})
+	r := createMultipartRequest(t, "/changelog", base, revision)
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "response-property-type-changed")
+}
+
+func TestChangelogFromFile_TarGzEntrypoint(t *testing.T) {
+
+	files := map[string]string{"api/spec.yaml": archiveSpec, "api/schemas/user.yaml": "type: object\n"}
+	r := createMultipartRequest(t, "/changelog", createTarGz(t, files), createTarGz(t, files))
+	r.URL.RawQuery = "entrypoint=api/spec.yaml"
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+}
+
+func TestChangelogFromFile_ArchiveWithoutEntrypoint(t *testing.T) {
+
+	files := map[string]string{"api/spec.yaml": archiveSpec}
+	r := createMultipartRequest(t, "/changelog", createZip(t, files), createZip(t, files))
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeMissingParameter, problem.Type)
+	require.Equal(t, "entrypoint", problem.Input)
+}
+
+func TestChangelogFromFile_ArchiveEntryOutside(t *testing.T) {
+
+	files := map[string]string{"openapi.yaml": archiveSpec, "../escape.yaml": "type: object\n"}
+	r := createMultipartRequest(t, "/changelog", createTarGz(t, files), createTarGz(t, files))
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeInvalidRequest, problem.Type)
+	require.Equal(t, internal.InputBase, problem.Input)
+}
+
+func TestChangelogFromFile_ArchiveRefOutside(t *testing.T) {
+
+	files := map[string]string{"openapi.yaml": strings.ReplaceAll(archiveSpec, "schemas/user.yaml", "../../../../etc/passwd")}
+	r := createMultipartRequest(t, "/changelog", createZip(t, files), createZip(t, files))
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeSpecLoadFailed, problem.Type)
+	require.NotContains(t, problem.Detail, "oasdiff-archive-")
+}
+
+func TestChangelogFromFile_ArchiveTooManyFiles(t *testing.T) {
+
+	files := map[string]string{"openapi.yaml": archiveSpec, "schemas/user.yaml": "type: object\n"}
+	r := createMultipartRequest(t, "/changelog", createZip(t, files), createZip(t, files))
+	w := httptest.NewRecorder()
+
+	limits := internal.NewLimits()
+	limits.MaxArchiveFiles = 1
+	internal.NewHandler(nil, nil, limits, createFetcher(), nil).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypePayloadTooLarge, decodeProblem(t, w).Type)
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/oasdiff/oasdiff-service/internal/badge"
+	"github.com/oasdiff/oasdiff/checker"
+)
+
+const (
+	HeaderImageSvg     = "image/svg+xml"
+	HeaderImagePng     = "image/png"
+	HeaderCacheControl = "Cache-Control"
+	HeaderVary         = "Vary"
+
+	BADGE_LABEL = "breaking changes"
+)
+
+func (h *Handler) BadgeFromUri(w http.ResponseWriter, r *http.Request) {
+
+	r = h.withTenantSettings(r)
+	style := GetQueryString(r, "style", badge.STYLE_FLAT)
+	if !slices.Contains(badge.Styles, style) {
+		writeProblem(w, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("unsupported badge style '%s'", style)).WithInput("style"))
+		return
+	}
+
+	var logo *badge.Logo
+	if dataURI := GetQueryString(r, "logo", ""); dataURI != "" {
+		var err error
+		if logo, err = badge.NewLogo(dataURI); err != nil {
+			writeProblem(w, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("invalid badge logo with %v", err)).WithInput("logo"))
+			return
+		}
+	}
+
+	limits := h.getLimits(r)
+	sources, options, err := ReadSpecSources(w, r, limits, h.fetcher)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	contentType, err := getBadgeContentType(r, options)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+	if contentType == HeaderImagePng && logo != nil && !logo.IsRasterizable() {
+		writeProblem(w, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, badge.ErrLogoNotRasterizable.Error()).WithInput("logo"))
+		return
+	}
+
+	specs, err := resolveSpecSources(sources, options, limits, h.fetcher)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	changes, err := h.calcChangelog(r, specs, options, BREAKING_LEVEL)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	message, color := getBadgeMessage(changes)
+	out, err := h.generateBadge(contentType, style, message, color, logo)
+	if err != nil {
+		writeProblem(w, NewProblem(http.StatusInternalServerError, ProblemTypeRenderFailed, err.Error()))
+		return
+	}
+
+	// badges are embedded in READMEs, make sure proxies such as GitHub's camo always fetch a fresh one
+	w.Header().Set(HeaderCacheControl, "no-cache, no-store, must-revalidate")
+	w.Header().Set(HeaderVary, HeaderAccept)
+	w.Header().Set(HeaderContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+func (h *Handler) generateBadge(contentType string, style string, message string, color string, logo *badge.Logo) ([]byte, error) {
+
+	if contentType == HeaderImagePng {
+		return h.badgeGenerator.PNG(style, BADGE_LABEL, message, color, logo)
+	}
+
+	return h.badgeGenerator.Generate(style, BADGE_LABEL, message, color, logo)
+}
+
+// getBadgeContentType returns the badge format of the format option or the Accept header, SVG if neither is acceptable since badges are embedded as images
+func getBadgeContentType(r *http.Request, options *Options) (string, error) {
+
+	contentType, err := negotiateContentType(r, options.Format, badgeMediaTypes)
+	if err != nil {
+		var problem *Problem
+		if errors.As(err, &problem) && problem.Status == http.StatusNotAcceptable {
+			return HeaderImageSvg, nil
+		}
+		return "", err
+	}
+
+	return contentType, nil
+}
+
+// getBadgeMessage returns the badge message and the color matching the highest severity found
+func getBadgeMessage(changes checker.Changes) (string, string) {
+
+	count := changes.GetLevelCount()
+	errs, warnings := count[checker.ERR], count[checker.WARN]
+
+	var parts []string
+	if errs > 0 {
+		parts = append(parts, pluralize(errs, "error"))
+	}
+	if warnings > 0 {
+		parts = append(parts, pluralize(warnings, "warning"))
+	}
+
+	switch {
+	case errs > 0:
+		return strings.Join(parts, ", "), badge.COLOR_CRITICAL
+	case warnings > 0:
+		return strings.Join(parts, ", "), badge.COLOR_IMPORTANT
+	default:
+		return "none", badge.COLOR_SUCCESS
+	}
+}
+
+func pluralize(count int, noun string) string {
+
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", count, noun)
+}
@@ -1,15 +1,20 @@
 package badge
 
 import (
+	"container/list"
+	_ "embed"
 	"fmt"
 	"log/slog"
 	"math"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
 )
 
 const (
@@ -30,11 +35,28 @@ const (
 	COLOR_INACTIVE      = "#9f9f9f"
 )
 
+const (
+	STYLE_FLAT          = "flat"
+	STYLE_FLAT_SQUARE   = "flat-square"
+	STYLE_PLASTIC       = "plastic"
+	STYLE_FOR_THE_BADGE = "for-the-badge"
+	STYLE_SOCIAL        = "social"
+)
+
+// Styles lists the supported badge styles
+var Styles = []string{STYLE_FLAT, STYLE_FLAT_SQUARE, STYLE_PLASTIC, STYLE_FOR_THE_BADGE, STYLE_SOCIAL}
+
 const (
 	DEFAULT_OFFSET  = 9 // default font offset
 	DEFAULT_SPACING = 0 // default letter spacing
 )
 
+// MAX_CACHED_WIDTHS bounds the number of measured strings kept by a generator
+const MAX_CACHED_WIDTHS = 1024
+
+//go:embed verdana.ttf
+var defaultFont []byte
+
 type Generator struct {
 	Offset  int     // Text offset
 	Spacing float64 // Letter spacing
@@ -42,6 +64,21 @@ type Generator struct {
 	fontSize int
 	fontName string
 	drawer   *font.Drawer
+
+	mu     sync.Mutex               // font.Drawer is not safe for concurrent use
+	widths map[string]*list.Element // cache of measured string widths
+	order  *list.List               // of *cachedWidth, most recently used first
+}
+
+type cachedWidth struct {
+	text  string
+	width fixed.Int26_6
+}
+
+// NewDefaultGenerator creates a generator using the font embedded in the binary
+func NewDefaultGenerator(fontSize int) (*Generator, error) {
+
+	return NewGeneratorFromData(defaultFont, fontSize)
 }
 
 func NewGenerator(fontFile string, fontSize int) (*Generator, error) {
@@ -52,9 +89,14 @@ func NewGenerator(fontFile string, fontSize int) (*Generator, error) {
 		return nil, err
 	}
 
+	return NewGeneratorFromData(fontData, fontSize)
+}
+
+func NewGeneratorFromData(fontData []byte, fontSize int) (*Generator, error) {
+
 	fontTTF, err := truetype.Parse(fontData)
 	if err != nil {
-		slog.Error("failed to parse fonts", "file", fontFile, "error", err)
+		slog.Error("failed to parse fonts", "error", err)
 		return nil, err
 	}
 
@@ -72,42 +114,246 @@ func NewGenerator(fontFile string, fontSize int) (*Generator, error) {
 				Hinting: font.HintingFull,
 			}),
 		},
+		widths: map[string]*list.Element{},
+		order:  list.New(),
 	}, nil
 }
 
 // Flat generates SVG badge in flat style
-func (g *Generator) Flat(label, message, color string) []byte {
+func (g *Generator) Flat(label, message, color string, logo *Logo) []byte {
+
+	l := g.newLayout(STYLE_FLAT, logo)
+	if label == "" {
+		const _TEMPLATE_FLAT_SIMPLE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{MESSAGE}"><title>{MESSAGE}</title><linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient><clipPath id="r"><rect width="{WIDTH}" height="20" rx="3" fill="#fff"/></clipPath><g clip-path="url(#r)"><rect width="0" height="20" fill="{COLOR}"/><rect x="0" width="{WIDTH}" height="20" fill="{COLOR}"/><rect width="{WIDTH}" height="20" fill="url(#s)"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text aria-hidden="true" x="{MESSAGE_X}" y="150" fill="{MESSAGE_SHADOW}" fill-opacity=".3" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="140" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+		return g.generateBadgeSimple(_TEMPLATE_FLAT_SIMPLE, l, message, color)
+	}
+
+	const _TEMPLATE_FLAT = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{LABEL}: {MESSAGE}"><title>{LABEL}: {MESSAGE}</title><linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient><clipPath id="r"><rect width="{WIDTH}" height="20" rx="3" fill="#fff"/></clipPath><g clip-path="url(#r)"><rect width="{LABEL_WIDTH}" height="20" fill="#555"/><rect x="{LABEL_WIDTH}" width="{MESSAGE_WIDTH}" height="20" fill="{COLOR}"/><rect width="{WIDTH}" height="20" fill="url(#s)"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text aria-hidden="true" x="{LABEL_X}" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)" textLength="{LABEL_LENGTH}">{LABEL}</text><text x="{LABEL_X}" y="140" transform="scale(.1)" fill="#fff" textLength="{LABEL_LENGTH}">{LABEL}</text><text aria-hidden="true" x="{MESSAGE_X}" y="150" fill="{MESSAGE_SHADOW}" fill-opacity=".3" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="140" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+	return g.generateBadge(_TEMPLATE_FLAT, l, label, message, color)
+}
+
+// FlatSquare generates SVG badge in flat-square style
+func (g *Generator) FlatSquare(label, message, color string, logo *Logo) []byte {
+
+	l := g.newLayout(STYLE_FLAT_SQUARE, logo)
+	if label == "" {
+		const _TEMPLATE_FLAT_SQUARE_SIMPLE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{MESSAGE}"><title>{MESSAGE}</title><g shape-rendering="crispEdges"><rect width="{WIDTH}" height="20" fill="{COLOR}"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text x="{MESSAGE_X}" y="140" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+		return g.generateBadgeSimple(_TEMPLATE_FLAT_SQUARE_SIMPLE, l, message, color)
+	}
+
+	const _TEMPLATE_FLAT_SQUARE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{LABEL}: {MESSAGE}"><title>{LABEL}: {MESSAGE}</title><g shape-rendering="crispEdges"><rect width="{LABEL_WIDTH}" height="20" fill="#555"/><rect x="{LABEL_WIDTH}" width="{MESSAGE_WIDTH}" height="20" fill="{COLOR}"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text x="{LABEL_X}" y="140" transform="scale(.1)" fill="#fff" textLength="{LABEL_LENGTH}">{LABEL}</text><text x="{MESSAGE_X}" y="140" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+	return g.generateBadge(_TEMPLATE_FLAT_SQUARE, l, label, message, color)
+}
+
+// Plastic generates SVG badge in plastic style
+func (g *Generator) Plastic(label, message, color string, logo *Logo) []byte {
+
+	l := g.newLayout(STYLE_PLASTIC, logo)
+	if label == "" {
+		const _TEMPLATE_PLASTIC_SIMPLE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="18" role="img" aria-label="{MESSAGE}"><title>{MESSAGE}</title><linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#fff" stop-opacity=".7"/><stop offset=".1" stop-color="#aaa" stop-opacity=".1"/><stop offset=".9" stop-color="#000" stop-opacity=".3"/><stop offset="1" stop-color="#000" stop-opacity=".5"/></linearGradient><clipPath id="r"><rect width="{WIDTH}" height="18" rx="4" fill="#fff"/></clipPath><g clip-path="url(#r)"><rect width="{WIDTH}" height="18" fill="{COLOR}"/><rect width="{WIDTH}" height="18" fill="url(#s)"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text aria-hidden="true" x="{MESSAGE_X}" y="140" fill="{MESSAGE_SHADOW}" fill-opacity=".3" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="130" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+		return g.generateBadgeSimple(_TEMPLATE_PLASTIC_SIMPLE, l, message, color)
+	}
+
+	const _TEMPLATE_PLASTIC = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="18" role="img" aria-label="{LABEL}: {MESSAGE}"><title>{LABEL}: {MESSAGE}</title><linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#fff" stop-opacity=".7"/><stop offset=".1" stop-color="#aaa" stop-opacity=".1"/><stop offset=".9" stop-color="#000" stop-opacity=".3"/><stop offset="1" stop-color="#000" stop-opacity=".5"/></linearGradient><clipPath id="r"><rect width="{WIDTH}" height="18" rx="4" fill="#fff"/></clipPath><g clip-path="url(#r)"><rect width="{LABEL_WIDTH}" height="18" fill="#555"/><rect x="{LABEL_WIDTH}" width="{MESSAGE_WIDTH}" height="18" fill="{COLOR}"/><rect width="{WIDTH}" height="18" fill="url(#s)"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text aria-hidden="true" x="{LABEL_X}" y="140" fill="#010101" fill-opacity=".3" transform="scale(.1)" textLength="{LABEL_LENGTH}">{LABEL}</text><text x="{LABEL_X}" y="130" transform="scale(.1)" fill="#fff" textLength="{LABEL_LENGTH}">{LABEL}</text><text aria-hidden="true" x="{MESSAGE_X}" y="140" fill="{MESSAGE_SHADOW}" fill-opacity=".3" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="130" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+	return g.generateBadge(_TEMPLATE_PLASTIC, l, label, message, color)
+}
+
+// ForTheBadge generates SVG badge in for-the-badge style
+func (g *Generator) ForTheBadge(label, message, color string, logo *Logo) []byte {
 
+	l := g.newLayout(STYLE_FOR_THE_BADGE, logo)
 	if label == "" {
-		const _TEMPLATE_FLAT_SIMPLE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{MESSAGE}"><title>{MESSAGE}</title><linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient><clipPath id="r"><rect width="{WIDTH}" height="20" rx="3" fill="#fff"/></clipPath><g clip-path="url(#r)"><rect width="0" height="20" fill="{COLOR}"/><rect x="0" width="{WIDTH}" height="20" fill="{COLOR}"/><rect width="{WIDTH}" height="20" fill="url(#s)"/></g><g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text aria-hidden="true" x="{MESSAGE_X}" y="150" fill="{MESSAGE_SHADOW}" fill-opacity=".3" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="140" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
-		return g.generateBadgeSimple(_TEMPLATE_FLAT_SIMPLE, message, color)
+		const _TEMPLATE_FOR_THE_BADGE_SIMPLE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="28" role="img" aria-label="{MESSAGE}"><title>{MESSAGE}</title><g shape-rendering="crispEdges"><rect width="{WIDTH}" height="28" fill="{COLOR}"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text x="{MESSAGE_X}" y="175" transform="scale(.1)" fill="{MESSAGE_COLOR}" font-weight="bold" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+		return g.generateBadgeSimple(_TEMPLATE_FOR_THE_BADGE_SIMPLE, l, message, color)
 	}
 
-	const _TEMPLATE_FLAT = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{LABEL}: {MESSAGE}"><title>{LABEL}: {MESSAGE}</title><linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient><clipPath id="r"><rect width="{WIDTH}" height="20" rx="3" fill="#fff"/></clipPath><g clip-path="url(#r)"><rect width="{LABEL_WIDTH}" height="20" fill="#555"/><rect x="{LABEL_WIDTH}" width="{MESSAGE_WIDTH}" height="20" fill="{COLOR}"/><rect width="{WIDTH}" height="20" fill="url(#s)"/></g><g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text aria-hidden="true" x="{LABEL_X}" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)" textLength="{LABEL_LENGTH}">{LABEL}</text><text x="{LABEL_X}" y="140" transform="scale(.1)" fill="#fff" textLength="{LABEL_LENGTH}">{LABEL}</text><text aria-hidden="true" x="{MESSAGE_X}" y="150" fill="{MESSAGE_SHADOW}" fill-opacity=".3" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="140" transform="scale(.1)" fill="{MESSAGE_COLOR}" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
-	return g.generateBadge(_TEMPLATE_FLAT, label, message, color)
+	const _TEMPLATE_FOR_THE_BADGE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="28" role="img" aria-label="{LABEL}: {MESSAGE}"><title>{LABEL}: {MESSAGE}</title><g shape-rendering="crispEdges"><rect width="{LABEL_WIDTH}" height="28" fill="#555"/><rect x="{LABEL_WIDTH}" width="{MESSAGE_WIDTH}" height="28" fill="{COLOR}"/></g>{LOGO}<g fill="#fff" text-anchor="middle" font-family="{FONT},Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="{FONT_SIZE}"><text x="{LABEL_X}" y="175" transform="scale(.1)" fill="#fff" textLength="{LABEL_LENGTH}">{LABEL}</text><text x="{MESSAGE_X}" y="175" transform="scale(.1)" fill="{MESSAGE_COLOR}" font-weight="bold" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+	return g.generateBadge(_TEMPLATE_FOR_THE_BADGE, l, label, message, color)
+}
+
+// Social generates SVG badge in social style, the color is ignored as in shields.io
+func (g *Generator) Social(label, message, color string, logo *Logo) []byte {
+
+	l := g.newLayout(STYLE_SOCIAL, logo)
+	if label == "" {
+		const _TEMPLATE_SOCIAL_SIMPLE = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{MESSAGE}"><title>{MESSAGE}</title><linearGradient id="a" x2="0" y2="100%"><stop offset="0" stop-color="#fcfcfc" stop-opacity="0"/><stop offset="1" stop-opacity=".1"/></linearGradient><g stroke="#d5d5d5"><rect stroke="none" fill="#fcfcfc" x="0.5" y="0.5" width="{WIDTH}" height="19" rx="2"/><rect fill="url(#a)" x="0.5" y="0.5" width="{WIDTH}" height="19" rx="2"/></g>{LOGO}<g aria-hidden="true" fill="#333" text-anchor="middle" font-family="{FONT},Helvetica,Arial,sans-serif" text-rendering="geometricPrecision" font-weight="700" font-size="{FONT_SIZE}"><text x="{MESSAGE_X}" y="150" fill="#fff" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="140" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+		return g.generateBadgeSimple(_TEMPLATE_SOCIAL_SIMPLE, l, message, "#fcfcfc")
+	}
+
+	const _TEMPLATE_SOCIAL = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{WIDTH}" height="20" role="img" aria-label="{LABEL}: {MESSAGE}"><title>{LABEL}: {MESSAGE}</title><linearGradient id="a" x2="0" y2="100%"><stop offset="0" stop-color="#fcfcfc" stop-opacity="0"/><stop offset="1" stop-opacity=".1"/></linearGradient><g stroke="#d5d5d5"><rect stroke="none" fill="#fcfcfc" x="0.5" y="0.5" width="{LABEL_WIDTH}" height="19" rx="2"/><rect fill="url(#a)" x="0.5" y="0.5" width="{LABEL_WIDTH}" height="19" rx="2"/><rect x="{MESSAGE_BOX_X}" y="0.5" width="{MESSAGE_WIDTH}" height="19" rx="2" fill="#fafafa"/><rect x="{MESSAGE_BOX_X}" y="7.5" width="0.5" height="5" stroke="#fafafa"/><path d="M{MESSAGE_BOX_X} 6.5 l-3 3v1 l3 3" fill="#fafafa"/></g>{LOGO}<g aria-hidden="true" fill="#333" text-anchor="middle" font-family="{FONT},Helvetica,Arial,sans-serif" text-rendering="geometricPrecision" font-weight="700" font-size="{FONT_SIZE}"><text x="{LABEL_X}" y="150" fill="#fff" transform="scale(.1)" textLength="{LABEL_LENGTH}">{LABEL}</text><text x="{LABEL_X}" y="140" transform="scale(.1)" textLength="{LABEL_LENGTH}">{LABEL}</text><text x="{MESSAGE_X}" y="150" fill="#fff" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text><text x="{MESSAGE_X}" y="140" transform="scale(.1)" textLength="{MESSAGE_LENGTH}">{MESSAGE}</text></g></svg>`
+	return g.generateBadge(_TEMPLATE_SOCIAL, l, label, message, color)
+}
+
+// Generate generates SVG badge in the given style
+func (g *Generator) Generate(style, label, message, color string, logo *Logo) ([]byte, error) {
+
+	switch style {
+	case STYLE_FLAT, "":
+		return g.Flat(label, message, color, logo), nil
+	case STYLE_FLAT_SQUARE:
+		return g.FlatSquare(label, message, color, logo), nil
+	case STYLE_PLASTIC:
+		return g.Plastic(label, message, color, logo), nil
+	case STYLE_FOR_THE_BADGE:
+		return g.ForTheBadge(label, message, color, logo), nil
+	case STYLE_SOCIAL:
+		return g.Social(label, message, color, logo), nil
+	default:
+		return nil, fmt.Errorf("unsupported badge style '%s'", style)
+	}
+}
+
+// layout describes the geometry of a badge style
+type layout struct {
+	height        float64 // badge height
+	offset        float64 // horizontal padding around each text
+	gap           float64 // space between the label and the message boxes
+	letterSpacing float64 // extra space added after each letter
+	upperCase     bool    // render text in upper case
+	logo          *Logo   // optional logo rendered left of the label
 }
 
-func (g *Generator) generateBadge(template, label, message, color string) []byte {
+// newLayout returns the layout of the given style
+func (g *Generator) newLayout(style string, logo *Logo) layout {
+
+	l := layout{height: 20, offset: float64(g.Offset), logo: logo}
+
+	switch style {
+	case STYLE_PLASTIC:
+		l.height = 18
+	case STYLE_FOR_THE_BADGE:
+		// for-the-badge renders taller and wider, letter-spaced upper case text
+		l.height = 28
+		l.offset *= 2
+		l.letterSpacing = 1.25
+		l.upperCase = true
+	case STYLE_SOCIAL:
+		// the message is rendered in a separate speech bubble next to the label
+		l.gap = 6
+	}
+
+	return l
+}
+
+// text applies the text transformation of the layout
+func (l layout) text(s string) string {
+
+	if l.upperCase {
+		return strings.ToUpper(s)
+	}
+
+	return s
+}
+
+// logoWidth returns the horizontal space taken by the logo
+func (l layout) logoWidth() float64 {
+
+	if l.logo == nil {
+		return 0
+	}
+
+	return LOGO_SIZE + LOGO_PADDING
+}
+
+// logoY returns the top edge of the logo, centered vertically
+func (l layout) logoY() float64 {
+	return (l.height - LOGO_SIZE) / 2
+}
+
+// geometry holds the positions of the badge elements, in pixels
+type geometry struct {
+	width         float64 // full badge width
+	labelWidth    float64 // width of the label box, including the logo
+	messageBoxX   float64 // left edge of the message box
+	messageWidth  float64 // width of the message box
+	labelX        float64 // center of the label text
+	messageX      float64 // center of the message text
+	labelLength   float64 // width of the label text
+	messageLength float64 // width of the message text
+}
+
+func (g *Generator) measureBadge(l layout, label, message string) geometry {
+
+	lgW := l.logoWidth()
+	lW := g.measure(l, label) + lgW
+	mW := g.measure(l, message)
+	mBX := lW + l.gap
+
+	return geometry{
+		width:         mBX + mW,
+		labelWidth:    lW,
+		messageBoxX:   mBX,
+		messageWidth:  mW,
+		labelX:        lgW + (lW-lgW)/2 + 1,
+		messageX:      mBX + (mW / 2) - 1,
+		labelLength:   lW - lgW - l.offset,
+		messageLength: mW - l.offset,
+	}
+}
+
+func (g *Generator) measureBadgeSimple(l layout, message string) geometry {
+
+	lgW := l.logoWidth()
+	fW := g.measure(l, message) + lgW
+
+	return geometry{
+		width:         fW,
+		labelWidth:    lgW,
+		messageWidth:  fW,
+		messageX:      lgW + (fW-lgW)/2,
+		messageLength: fW - lgW - l.offset,
+	}
+}
+
+// measure returns the width of the given text including the padding and letter spacing of the layout
+func (g *Generator) measure(l layout, text string) float64 {
+	return float64(g.measureString(text)>>6) + l.offset + l.letterSpacing*float64(utf8.RuneCountInString(text))
+}
+
+// measureString returns the width of the given text, caching the widths of the most recently measured strings
+func (g *Generator) measureString(text string) fixed.Int26_6 {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if elem, ok := g.widths[text]; ok {
+		g.order.MoveToFront(elem)
+		return elem.Value.(*cachedWidth).width
+	}
+
+	if g.order.Len() >= MAX_CACHED_WIDTHS {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.widths, oldest.Value.(*cachedWidth).text)
+	}
+
+	width := g.drawer.MeasureString(text)
+	g.widths[text] = g.order.PushFront(&cachedWidth{text: text, width: width})
+
+	return width
+}
+
+func (g *Generator) generateBadge(template string, l layout, label, message, color string) []byte {
 	c := parseColor(color)
 
-	gF := float64(g.Offset)
-	lW := float64(g.drawer.MeasureString(label)>>6) + gF
-	mW := float64(g.drawer.MeasureString(message)>>6) + gF
-	fW := lW + mW
-	lX := (lW/2 + 1) * 10
-	mX := (lW + (mW / 2) - 1) * 10
-	lL := (lW - gF) * (10.0 + g.Spacing - 0.5)
-	mL := (mW - gF) * (10.0 + g.Spacing - 0.5)
+	label, message = l.text(label), l.text(message)
+	gm := g.measureBadge(l, label, message)
+	lX := gm.labelX * 10
+	mX := gm.messageX * 10
+	lL := gm.labelLength * (10.0 + g.Spacing - 0.5)
+	mL := gm.messageLength * (10.0 + g.Spacing - 0.5)
 	fS := g.fontSize * 10
 
 	mC, mS := getMessageColors(c)
 
-	badge := strings.ReplaceAll(template, "{LABEL}", label)
+	badge := strings.ReplaceAll(template, "{LOGO}", l.logoElement())
+	badge = strings.ReplaceAll(badge, "{LABEL}", label)
 	badge = strings.ReplaceAll(badge, "{MESSAGE}", message)
 	badge = strings.ReplaceAll(badge, "{COLOR}", formatColor(c))
-	badge = strings.ReplaceAll(badge, "{WIDTH}", formatFloat(fW))
-	badge = strings.ReplaceAll(badge, "{LABEL_WIDTH}", formatFloat(lW))
-	badge = strings.ReplaceAll(badge, "{MESSAGE_WIDTH}", formatFloat(mW))
+	badge = strings.ReplaceAll(badge, "{WIDTH}", formatFloat(gm.width))
+	badge = strings.ReplaceAll(badge, "{LABEL_WIDTH}", formatFloat(gm.labelWidth))
+	badge = strings.ReplaceAll(badge, "{MESSAGE_WIDTH}", formatFloat(gm.messageWidth))
+	badge = strings.ReplaceAll(badge, "{MESSAGE_BOX_X}", formatFloat(gm.messageBoxX))
 	badge = strings.ReplaceAll(badge, "{LABEL_X}", formatFloat(lX))
 	badge = strings.ReplaceAll(badge, "{MESSAGE_X}", formatFloat(mX))
 	badge = strings.ReplaceAll(badge, "{LABEL_LENGTH}", formatFloat(lL))
@@ -120,20 +366,21 @@ func (g *Generator) generateBadge(template, label, message, color string) []byte
 	return []byte(badge)
 }
 
-func (g *Generator) generateBadgeSimple(template, message, color string) []byte {
+func (g *Generator) generateBadgeSimple(template string, l layout, message, color string) []byte {
 	c := parseColor(color)
 
-	gF := float64(g.Offset)
-	fW := float64(g.drawer.MeasureString(message)>>6) + gF
-	mX := (fW / 2) * 10
-	mL := (fW - gF) * (10.0 + g.Spacing)
+	message = l.text(message)
+	gm := g.measureBadgeSimple(l, message)
+	mX := gm.messageX * 10
+	mL := gm.messageLength * (10.0 + g.Spacing)
 	fS := g.fontSize * 10
 
 	mC, mS := getMessageColors(c)
 
-	badge := strings.ReplaceAll(template, "{MESSAGE}", message)
+	badge := strings.ReplaceAll(template, "{LOGO}", l.logoElement())
+	badge = strings.ReplaceAll(badge, "{MESSAGE}", message)
 	badge = strings.ReplaceAll(badge, "{COLOR}", formatColor(c))
-	badge = strings.ReplaceAll(badge, "{WIDTH}", formatFloat(fW))
+	badge = strings.ReplaceAll(badge, "{WIDTH}", formatFloat(gm.width))
 	badge = strings.ReplaceAll(badge, "{MESSAGE_X}", formatFloat(mX))
 	badge = strings.ReplaceAll(badge, "{MESSAGE_LENGTH}", formatFloat(mL))
 	badge = strings.ReplaceAll(badge, "{MESSAGE_COLOR}", mC)
@@ -144,6 +391,17 @@ func (g *Generator) generateBadgeSimple(template, message, color string) []byte
 	return []byte(badge)
 }
 
+// logoElement returns the SVG image element of the logo, or an empty string if there is none
+func (l layout) logoElement() string {
+
+	if l.logo == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(`<image x="5" y="%s" width="%d" height="%d" xlink:href="%s"/>`,
+		formatFloat(l.logoY()), LOGO_SIZE, LOGO_SIZE, l.logo.DataURI())
+}
+
 // parseColor parses hex color
 func parseColor(c string) int64 {
 	if strings.HasPrefix(c, "#") {
@@ -1,6 +1,8 @@
 package badge_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/oasdiff/oasdiff-service/internal/badge"
@@ -11,5 +13,72 @@ func TestGenerator_GenerateFlat(t *testing.T) {
 
 	bg, err := badge.NewGenerator("verdana.ttf", 11)
 	require.NoError(t, err)
-	require.NotEmpty(t, bg.Flat("changelog", "v1.23.4", badge.COLOR_BLUE))
+	require.NotEmpty(t, bg.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil))
+}
+
+func TestGenerator_Generate(t *testing.T) {
+
+	bg, err := badge.NewGenerator("verdana.ttf", 11)
+	require.NoError(t, err)
+
+	for _, style := range badge.Styles {
+		out, err := bg.Generate(style, "breaking changes", "2 errors", badge.COLOR_CRITICAL, nil)
+		require.NoError(t, err, style)
+		require.Contains(t, strings.ToLower(string(out)), "breaking changes", style)
+
+		out, err = bg.Generate(style, "", "none", badge.COLOR_SUCCESS, nil)
+		require.NoError(t, err, style)
+		require.Contains(t, strings.ToLower(string(out)), "none", style)
+	}
+}
+
+func TestGenerator_GenerateForTheBadge(t *testing.T) {
+
+	bg, err := badge.NewGenerator("verdana.ttf", 11)
+	require.NoError(t, err)
+	require.Contains(t, string(bg.ForTheBadge("changelog", "v1.23.4", badge.COLOR_BLUE, nil)), "CHANGELOG")
+}
+
+func TestGenerator_GenerateUnsupportedStyle(t *testing.T) {
+
+	bg, err := badge.NewGenerator("verdana.ttf", 11)
+	require.NoError(t, err)
+	_, err = bg.Generate("3d", "changelog", "v1.23.4", badge.COLOR_BLUE, nil)
+	require.Error(t, err)
+}
+
+func TestGenerator_DefaultFont(t *testing.T) {
+
+	bg, err := badge.NewDefaultGenerator(11)
+	require.NoError(t, err)
+
+	fromFile, err := badge.NewGenerator("verdana.ttf", 11)
+	require.NoError(t, err)
+
+	// the embedded font renders exactly like the same font loaded from disk, also when widths are cached
+	require.Equal(t, fromFile.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil), bg.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil))
+	require.Equal(t, fromFile.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil), bg.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil))
+}
+
+func TestGenerator_CachedWidthsEvicted(t *testing.T) {
+
+	bg, err := badge.NewDefaultGenerator(11)
+	require.NoError(t, err)
+
+	fromFile, err := badge.NewGenerator("verdana.ttf", 11)
+	require.NoError(t, err)
+
+	// measuring more strings than are cached evicts the least recently used widths without changing the badges
+	expected := fromFile.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil)
+	require.Equal(t, expected, bg.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil))
+	for i := range badge.MAX_CACHED_WIDTHS + 1 {
+		bg.Flat("", fmt.Sprintf("v%d", i), badge.COLOR_BLUE, nil)
+	}
+	require.Equal(t, expected, bg.Flat("changelog", "v1.23.4", badge.COLOR_BLUE, nil))
+}
+
+func TestGenerator_InvalidFont(t *testing.T) {
+
+	_, err := badge.NewGeneratorFromData([]byte("not a font"), 11)
+	require.Error(t, err)
 }
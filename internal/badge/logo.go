@@ -0,0 +1,90 @@
+package badge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+)
+
+const (
+	LOGO_SIZE     = 14        // logo width and height
+	LOGO_PADDING  = 3         // space between the logo and the label
+	MAX_LOGO_SIZE = 32 * 1024 // max size of a decoded logo
+	MAX_LOGO_DIM  = 256       // max width and height of a png logo, which is scaled down to LOGO_SIZE
+
+	MEDIA_TYPE_SVG = "image/svg+xml"
+	MEDIA_TYPE_PNG = "image/png"
+)
+
+// ErrLogoNotRasterizable is returned when an SVG logo is requested on a PNG badge
+var ErrLogoNotRasterizable = errors.New("svg logos can not be rendered in png badges, use a png logo instead")
+
+// Logo is an image rendered on the left of the badge label
+type Logo struct {
+	mediaType string
+	data      []byte
+	image     image.Image // decoded logo, nil for svg logos
+}
+
+// NewLogo parses a base64 data URI such as 'data:image/png;base64,...'
+func NewLogo(dataURI string) (*Logo, error) {
+
+	const prefix = "data:"
+
+	header, payload, found := strings.Cut(strings.TrimPrefix(dataURI, prefix), ",")
+	if !strings.HasPrefix(dataURI, prefix) || !found {
+		return nil, errors.New("logo must be a data URI")
+	}
+
+	mediaType, ok := strings.CutSuffix(header, ";base64")
+	if !ok {
+		return nil, errors.New("logo data URI must be base64 encoded")
+	}
+
+	if base64.StdEncoding.DecodedLen(len(payload)) > MAX_LOGO_SIZE {
+		return nil, fmt.Errorf("logo exceeds %d bytes", MAX_LOGO_SIZE)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode logo with %v", err)
+	}
+
+	switch mediaType {
+	case MEDIA_TYPE_SVG:
+		if !bytes.Contains(data, []byte("<svg")) {
+			return nil, errors.New("logo is not a valid svg image")
+		}
+		return &Logo{mediaType: mediaType, data: data}, nil
+	case MEDIA_TYPE_PNG:
+		// the dimensions are checked before decoding, since a small compressed png may declare huge dimensions
+		config, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("logo is not a valid png image with %v", err)
+		}
+		if config.Width > MAX_LOGO_DIM || config.Height > MAX_LOGO_DIM {
+			return nil, fmt.Errorf("logo exceeds %dx%d pixels", MAX_LOGO_DIM, MAX_LOGO_DIM)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("logo is not a valid png image with %v", err)
+		}
+		return &Logo{mediaType: mediaType, data: data, image: img}, nil
+	default:
+		return nil, fmt.Errorf("unsupported logo media type '%s'", mediaType)
+	}
+}
+
+// IsRasterizable reports whether the logo can be rendered in PNG badges
+func (l *Logo) IsRasterizable() bool {
+	return l.image != nil
+}
+
+// DataURI returns the logo as a base64 data URI, re-encoded so it is safe to embed in an SVG attribute
+func (l *Logo) DataURI() string {
+	return fmt.Sprintf("data:%s;base64,%s", l.mediaType, base64.StdEncoding.EncodeToString(l.data))
+}
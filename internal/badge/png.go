@@ -0,0 +1,187 @@
+package badge
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"unicode/utf8"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	COLOR_LABEL          = "#555"
+	COLOR_SOCIAL_LABEL   = "#fcfcfc"
+	COLOR_SOCIAL_MESSAGE = "#fafafa"
+	COLOR_SOCIAL_BORDER  = "#d5d5d5"
+	COLOR_SOCIAL_TEXT    = "#333"
+)
+
+// rasterStyle describes how a badge style is drawn when rasterized
+type rasterStyle struct {
+	radius   int  // corner radius
+	baseline int  // text baseline
+	shadow   bool // draw a shadow below the text
+	social   bool // draw light boxes with a border instead of colored ones
+}
+
+var rasterStyles = map[string]rasterStyle{
+	STYLE_FLAT:          {radius: 3, baseline: 14, shadow: true},
+	STYLE_FLAT_SQUARE:   {radius: 0, baseline: 14},
+	STYLE_PLASTIC:       {radius: 4, baseline: 13, shadow: true},
+	STYLE_FOR_THE_BADGE: {radius: 0, baseline: 18},
+	STYLE_SOCIAL:        {radius: 2, baseline: 14, social: true},
+}
+
+// PNG generates PNG badge in the given style, using the same layout as the SVG badge
+func (g *Generator) PNG(style, label, message, color string, logo *Logo) ([]byte, error) {
+
+	if style == "" {
+		style = STYLE_FLAT
+	}
+
+	rs, ok := rasterStyles[style]
+	if !ok {
+		return nil, fmt.Errorf("unsupported badge style '%s'", style)
+	}
+
+	if logo != nil && !logo.IsRasterizable() {
+		return nil, ErrLogoNotRasterizable
+	}
+
+	l := g.newLayout(style, logo)
+	label, message = l.text(label), l.text(message)
+
+	var gm geometry
+	if label == "" {
+		gm = g.measureBadgeSimple(l, message)
+	} else {
+		gm = g.measureBadge(l, label, message)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(math.Round(gm.width)), int(l.height)))
+
+	labelColor, messageColor := parseColor(COLOR_LABEL), parseColor(color)
+	labelText, labelShadow := parseColor("#fff"), parseColor("#010101")
+	mC, mS := getMessageColors(messageColor)
+	messageText, messageShadow := parseColor(mC), parseColor(mS)
+	if rs.social {
+		labelColor, messageColor = parseColor(COLOR_SOCIAL_LABEL), parseColor(COLOR_SOCIAL_MESSAGE)
+		labelText, messageText = parseColor(COLOR_SOCIAL_TEXT), parseColor(COLOR_SOCIAL_TEXT)
+	}
+
+	if label == "" {
+		fillBox(img, 0, gm.width, messageColor, rs.social)
+	} else {
+		fillBox(img, 0, gm.labelWidth, labelColor, rs.social)
+		fillBox(img, gm.messageBoxX, gm.width, messageColor, rs.social)
+	}
+
+	if logo != nil {
+		y := int(l.logoY())
+		xdraw.CatmullRom.Scale(img, image.Rect(5, y, 5+LOGO_SIZE, y+LOGO_SIZE), logo.image, logo.image.Bounds(), xdraw.Over, nil)
+	}
+
+	if label != "" {
+		if rs.shadow {
+			g.drawText(img, l, label, gm.labelX, rs.baseline+1, toColor(labelShadow, 0.3))
+		}
+		g.drawText(img, l, label, gm.labelX, rs.baseline, toColor(labelText, 1))
+	}
+	if rs.shadow {
+		g.drawText(img, l, message, gm.messageX, rs.baseline+1, toColor(messageShadow, 0.3))
+	}
+	g.drawText(img, l, message, gm.messageX, rs.baseline, toColor(messageText, 1))
+
+	roundCorners(img, rs.radius)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, fmt.Errorf("failed to encode png badge with %v", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// drawText draws the text centered around x, with the letter spacing of the layout
+func (g *Generator) drawText(img *image.NRGBA, l layout, text string, x float64, baseline int, c color.Color) {
+
+	width := float64(g.measureString(text)>>6) + l.letterSpacing*float64(utf8.RuneCountInString(text))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.drawer.Dst = img
+	g.drawer.Src = image.NewUniform(c)
+	g.drawer.Dot = fixed.P(int(math.Round(x-width/2)), baseline)
+	defer func() { g.drawer.Dst, g.drawer.Src = nil, nil }()
+
+	if l.letterSpacing == 0 {
+		g.drawer.DrawString(text)
+		return
+	}
+
+	spacing := fixed.Int26_6(l.letterSpacing * 64)
+	for _, r := range text {
+		g.drawer.DrawString(string(r))
+		g.drawer.Dot.X += spacing
+	}
+}
+
+// fillBox fills the columns between x0 and x1, adding a border in social style
+func fillBox(img *image.NRGBA, x0, x1 float64, c int64, border bool) {
+
+	rect := image.Rect(int(math.Round(x0)), 0, int(math.Round(x1)), img.Bounds().Dy())
+	xdraw.Draw(img, rect, image.NewUniform(toColor(c, 1)), image.Point{}, xdraw.Src)
+
+	if !border {
+		return
+	}
+
+	b := toColor(parseColor(COLOR_SOCIAL_BORDER), 1)
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		img.Set(x, rect.Min.Y, b)
+		img.Set(x, rect.Max.Y-1, b)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		img.Set(rect.Min.X, y, b)
+		img.Set(rect.Max.X-1, y, b)
+	}
+}
+
+// roundCorners makes the pixels outside of the rounded corners transparent
+func roundCorners(img *image.NRGBA, radius int) {
+
+	if radius == 0 {
+		return
+	}
+
+	b := img.Bounds()
+	r := float64(radius)
+	for y := 0; y < radius; y++ {
+		for x := 0; x < radius; x++ {
+			dx, dy := r-float64(x)-0.5, r-float64(y)-0.5
+			if dx*dx+dy*dy <= r*r {
+				continue
+			}
+			img.Set(b.Min.X+x, b.Min.Y+y, color.Transparent)
+			img.Set(b.Max.X-1-x, b.Min.Y+y, color.Transparent)
+			img.Set(b.Min.X+x, b.Max.Y-1-y, color.Transparent)
+			img.Set(b.Max.X-1-x, b.Max.Y-1-y, color.Transparent)
+		}
+	}
+}
+
+// toColor converts a parsed hex color and an opacity to a color
+func toColor(c int64, opacity float64) color.NRGBA {
+	return color.NRGBA{
+		R: uint8(c >> 16 & 0xFF),
+		G: uint8(c >> 8 & 0xFF),
+		B: uint8(c & 0xFF),
+		A: uint8(math.Round(opacity * 0xFF)),
+	}
+}
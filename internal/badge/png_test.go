@@ -0,0 +1,131 @@
+package badge_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"strconv"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal/badge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_PNG(t *testing.T) {
+
+	bg, err := badge.NewDefaultGenerator(11)
+	require.NoError(t, err)
+
+	svg := bg.Flat("breaking changes", "2 errors", badge.COLOR_CRITICAL, nil)
+
+	for _, style := range badge.Styles {
+		out, err := bg.PNG(style, "breaking changes", "2 errors", badge.COLOR_CRITICAL, nil)
+		require.NoError(t, err, style)
+		img, err := png.Decode(bytes.NewReader(out))
+		require.NoError(t, err, style)
+		require.Greater(t, img.Bounds().Dx(), 0, style)
+
+		if style == badge.STYLE_FLAT {
+			// the png has the same width as the svg
+			require.Contains(t, string(svg), `width="`+strconv.Itoa(img.Bounds().Dx())+`"`)
+		}
+	}
+}
+
+func TestGenerator_PNGWithLogo(t *testing.T) {
+
+	bg, err := badge.NewDefaultGenerator(11)
+	require.NoError(t, err)
+
+	logo, err := badge.NewLogo(createPNGDataURI(t))
+	require.NoError(t, err)
+
+	withLogo, err := bg.PNG(badge.STYLE_FLAT, "breaking changes", "none", badge.COLOR_SUCCESS, logo)
+	require.NoError(t, err)
+	withoutLogo, err := bg.PNG(badge.STYLE_FLAT, "breaking changes", "none", badge.COLOR_SUCCESS, nil)
+	require.NoError(t, err)
+
+	img1, err := png.Decode(bytes.NewReader(withLogo))
+	require.NoError(t, err)
+	img2, err := png.Decode(bytes.NewReader(withoutLogo))
+	require.NoError(t, err)
+	require.Equal(t, badge.LOGO_SIZE+badge.LOGO_PADDING, img1.Bounds().Dx()-img2.Bounds().Dx())
+}
+
+func TestGenerator_PNGWithSvgLogo(t *testing.T) {
+
+	bg, err := badge.NewDefaultGenerator(11)
+	require.NoError(t, err)
+
+	logo, err := badge.NewLogo(createSVGDataURI())
+	require.NoError(t, err)
+
+	_, err = bg.PNG(badge.STYLE_FLAT, "breaking changes", "none", badge.COLOR_SUCCESS, logo)
+	require.ErrorIs(t, err, badge.ErrLogoNotRasterizable)
+}
+
+func TestGenerator_SVGWithLogo(t *testing.T) {
+
+	bg, err := badge.NewDefaultGenerator(11)
+	require.NoError(t, err)
+
+	logo, err := badge.NewLogo(createSVGDataURI())
+	require.NoError(t, err)
+
+	for _, style := range badge.Styles {
+		out, err := bg.Generate(style, "breaking changes", "none", badge.COLOR_SUCCESS, logo)
+		require.NoError(t, err, style)
+		require.Contains(t, string(out), `xlink:href="`+createSVGDataURI()+`"`, style)
+
+		out, err = bg.Generate(style, "", "none", badge.COLOR_SUCCESS, logo)
+		require.NoError(t, err, style)
+		require.Contains(t, string(out), "<image", style)
+	}
+}
+
+func TestNewLogo_Invalid(t *testing.T) {
+
+	for _, dataURI := range []string{
+		"https://example.com/logo.svg",
+		"data:image/svg+xml,<svg></svg>",
+		"data:image/gif;base64,R0lGODlhAQABAAAAACw=",
+		"data:image/png;base64,not-base64",
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("not a png")),
+	} {
+		_, err := badge.NewLogo(dataURI)
+		require.Error(t, err, dataURI)
+	}
+}
+
+func TestNewLogo_HugeDimensions(t *testing.T) {
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+
+	// declare 30000x30000 pixels in the IHDR chunk, which follows the 8 byte signature, and fix its CRC
+	binary.BigEndian.PutUint32(data[16:20], 30000)
+	binary.BigEndian.PutUint32(data[20:24], 30000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	_, err := badge.NewLogo("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+	require.ErrorContains(t, err, "logo exceeds 256x256 pixels")
+}
+
+func createPNGDataURI(t *testing.T) string {
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 28, 28))))
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func createSVGDataURI() string {
+
+	const svg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 14 14"><circle cx="7" cy="7" r="7" fill="#fff"/></svg>`
+
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg))
+}
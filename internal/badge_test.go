@@ -0,0 +1,92 @@
+package internal_test
+
+import (
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/oasdiff/oasdiff-service/internal/badge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgeFromUri(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", specUri("openapi-test1.yaml"))
+	q.Add("revision", specUri("openapi-test3.yaml"))
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).BadgeFromUri(w, r)
+
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderImageSvg, w.Result().Header.Get(internal.HeaderContentType))
+	svg, err := io.ReadAll(w.Result().Body)
+	require.NoError(t, err)
+	require.Contains(t, string(svg), badge.COLOR_CRITICAL)
+}
+
+func TestBadgeFromUri_NoRevision(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", specUri("openapi-test1.yaml"))
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).BadgeFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestBadgeFromUri_UnsupportedStyle(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", specUri("openapi-test1.yaml"))
+	q.Add("revision", specUri("openapi-test3.yaml"))
+	q.Add("style", "3d")
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).BadgeFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestBadgeFromUri_PNG(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", specUri("openapi-test1.yaml"))
+	q.Add("revision", specUri("openapi-test3.yaml"))
+	r.URL.RawQuery = q.Encode()
+	r.Header.Set(internal.HeaderAccept, "image/png,image/*;q=0.8")
+	w := httptest.NewRecorder()
+
+	createHandler(t).BadgeFromUri(w, r)
+
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderImagePng, w.Result().Header.Get(internal.HeaderContentType))
+	_, err := png.Decode(w.Result().Body)
+	require.NoError(t, err)
+}
+
+func TestBadgeFromUri_InvalidLogo(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", specUri("openapi-test1.yaml"))
+	q.Add("revision", specUri("openapi-test3.yaml"))
+	q.Add("logo", "https://example.com/logo.svg")
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).BadgeFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
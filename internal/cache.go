@@ -0,0 +1,331 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oasdiff/go-common/env"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_CACHE_MEMORY_SIZE = 64 << 20
+	DEFAULT_CACHE_DISK_SIZE   = 1 << 30
+
+	HeaderETag        = "ETag"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// CacheConfig bounds the cache of rendered reports
+type CacheConfig struct {
+	MemorySize int64  // max size of the reports kept in memory, in bytes, 0 disables the memory tier
+	Dir        string // directory of the disk tier, empty disables it
+	DiskSize   int64  // max size of the reports kept on disk, in bytes
+}
+
+// NewCacheConfig returns the deployment's cache config, configured by environment variables
+func NewCacheConfig() CacheConfig {
+
+	return CacheConfig{
+		MemorySize: int64(env.GetIntWithDefault("CACHE_MEMORY_SIZE", DEFAULT_CACHE_MEMORY_SIZE)),
+		Dir:        env.GetWithDefault("CACHE_DIR", ""),
+		DiskSize:   int64(env.GetIntWithDefault("CACHE_DISK_SIZE", DEFAULT_CACHE_DISK_SIZE)),
+	}
+}
+
+// Cache keeps rendered reports by a key which hashes everything the report depends on, see getCacheKey.
+// Recently used reports are kept in memory, the optional disk tier keeps more of them and survives restarts.
+// A nil cache caches nothing.
+type Cache struct {
+	config  CacheConfig
+	memory  *lru[*recordedResponse]
+	trimmer sync.Mutex // serializes trimming the disk tier
+}
+
+// NewCache returns a cache with the config, or nil if both tiers are disabled
+func NewCache(config CacheConfig) (*Cache, error) {
+
+	if config.MemorySize <= 0 && config.Dir == "" {
+		return nil, nil
+	}
+
+	if config.Dir != "" {
+		if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory '%s' with %v", config.Dir, err)
+		}
+	}
+
+	return &Cache{
+		config: config,
+		memory: newLRU(config.MemorySize, (*recordedResponse).size),
+	}, nil
+}
+
+// get returns the response cached under the key, from memory or else from disk
+func (c *Cache) get(key string) (*recordedResponse, bool) {
+
+	if c == nil {
+		return nil, false
+	}
+
+	if res, ok := c.memory.get(key); ok {
+		return res, true
+	}
+
+	res, ok := c.readDisk(key)
+	if ok {
+		c.memory.put(key, res)
+	}
+
+	return res, ok
+}
+
+// put caches the response under the key, the response must not be changed afterwards
+func (c *Cache) put(key string, response *recordedResponse) {
+
+	if c == nil {
+		return
+	}
+
+	c.memory.put(key, response)
+	c.writeDisk(key, response)
+}
+
+// diskEntry is a cached response as stored on disk
+type diskEntry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	ETag   string      `json:"etag"`
+}
+
+func (c *Cache) getPath(key string) string {
+
+	return filepath.Join(c.config.Dir, key+".json")
+}
+
+func (c *Cache) readDisk(key string) (*recordedResponse, bool) {
+
+	if c.config.Dir == "" {
+		return nil, false
+	}
+
+	path := c.getPath(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("failed to read cached report '%s' with %v", path, err)
+		}
+		return nil, false
+	}
+
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Warnf("failed to decode cached report '%s', removing it with %v", path, err)
+		_ = os.Remove(path)
+		return nil, false
+	}
+
+	// the modification time orders the disk tier by use, so that trimming removes the least recently used reports
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	res := &recordedResponse{header: entry.Header, status: entry.Status, etag: entry.ETag}
+	res.body.Write(entry.Body)
+
+	return res, true
+}
+
+func (c *Cache) writeDisk(key string, response *recordedResponse) {
+
+	if c.config.Dir == "" || response.size() > c.config.DiskSize {
+		return
+	}
+
+	data, err := json.Marshal(diskEntry{Status: response.status, Header: response.header, Body: response.body.Bytes(), ETag: response.etag})
+	if err != nil {
+		log.Warnf("failed to encode report for the disk cache with %v", err)
+		return
+	}
+
+	// the report is written to a temporary file and renamed, so that concurrent readers never see a partial report
+	tmp, err := os.CreateTemp(c.config.Dir, key+".*.tmp")
+	if err != nil {
+		log.Warnf("failed to create report in the disk cache with %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.getPath(key))
+	}
+	if err != nil {
+		log.Warnf("failed to write report to the disk cache with %v", err)
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	c.trimDisk()
+}
+
+// trimDisk removes the least recently used reports until the disk tier is within its size
+func (c *Cache) trimDisk() {
+
+	c.trimmer.Lock()
+	defer c.trimmer.Unlock()
+
+	entries, err := os.ReadDir(c.config.Dir)
+	if err != nil {
+		log.Warnf("failed to list the disk cache with %v", err)
+		return
+	}
+
+	var files []os.FileInfo
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+
+	if size <= c.config.DiskSize {
+		return
+	}
+
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+	for _, file := range files {
+		if size <= c.config.DiskSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.config.Dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			log.Warnf("failed to remove '%s' from the disk cache with %v", file.Name(), err)
+			continue
+		}
+		size -= file.Size()
+	}
+}
+
+// recordedResponse records a response, to be written later or more than once, for jobs and cached reports
+type recordedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	etag   string
+}
+
+func newRecordedResponse() *recordedResponse {
+
+	return &recordedResponse{header: http.Header{}}
+}
+
+func (r *recordedResponse) Header() http.Header {
+
+	return r.header
+}
+
+func (r *recordedResponse) Write(p []byte) (int, error) {
+
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.body.Write(p)
+}
+
+func (r *recordedResponse) WriteHeader(status int) {
+
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// isReport tells whether the response is a report, rather than a problem, only reports are cached and tagged
+func (r *recordedResponse) isReport() bool {
+
+	return r.status < http.StatusBadRequest || r.status == http.StatusConflict
+}
+
+// setETag tags a report with a strong entity tag, the hash of its body
+func (r *recordedResponse) setETag() {
+
+	if !r.isReport() {
+		return
+	}
+
+	hash := sha256.Sum256(r.body.Bytes())
+	r.etag = `"` + hex.EncodeToString(hash[:16]) + `"`
+	r.header.Set(HeaderETag, r.etag)
+}
+
+// size approximates the memory used by the response, in bytes
+func (r *recordedResponse) size() int64 {
+
+	res := int64(r.body.Len() + len(r.etag))
+	for key, values := range r.header {
+		res += int64(len(key))
+		for _, value := range values {
+			res += int64(len(value))
+		}
+	}
+
+	return res
+}
+
+// writeTo writes the recorded response, unless the request's If-None-Match matches its entity tag.
+// A matching GET is answered with 304 Not Modified and other methods with 412 Precondition Failed, as RFC 9110 requires.
+func (r *recordedResponse) writeTo(w http.ResponseWriter, req *http.Request) {
+
+	if r.etag != "" && matchesETag(req.Header.Get(HeaderIfNoneMatch), r.etag) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set(HeaderETag, r.etag)
+			writeProblem(w, NewProblem(http.StatusPreconditionFailed, ProblemTypePreconditionFailed, fmt.Sprintf("the report matches '%s'", HeaderIfNoneMatch)))
+			return
+		}
+		for _, key := range []string{HeaderETag, HeaderContentLanguage, HeaderVary} {
+			if values := r.header.Values(key); len(values) > 0 {
+				w.Header()[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+			}
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	for key, values := range r.header {
+		w.Header()[key] = slices.Clone(values)
+	}
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body.Bytes())
+}
+
+// matchesETag tells whether an If-None-Match header matches the entity tag, using the weak comparison
+func matchesETag(ifNoneMatch string, etag string) bool {
+
+	for _, curr := range strings.Split(ifNoneMatch, ",") {
+		curr = strings.TrimSpace(curr)
+		if curr == "*" || strings.TrimPrefix(curr, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,261 @@
+package internal_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/oasdiff/go-common/ds"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func createCachedHandler(t *testing.T, config internal.CacheConfig) *internal.Handler {
+
+	cache, err := internal.NewCache(config)
+	require.NoError(t, err)
+
+	return internal.NewHandler(ds.NewInMemoryClient(nil), nil, internal.NewLimits(), createFetcher(), cache)
+}
+
+func createChangelogFileRequest(t *testing.T, revision string) *http.Request {
+
+	r := createMultipartRequest(t, "/tenants/test-tenant/changelog", readFile(t, "../data/openapi-test1.yaml"), readFile(t, revision))
+	r.Header.Set("Accept", internal.HeaderAppJson)
+
+	return r
+}
+
+func getCacheFiles(t *testing.T, dir string) []string {
+
+	res, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+
+	return res
+}
+
+func TestChangelogFromFile_Cached(t *testing.T) {
+
+	dir := t.TempDir()
+	config := internal.CacheConfig{MemorySize: 1 << 20, Dir: dir, DiskSize: 1 << 20}
+
+	first := httptest.NewRecorder()
+	createCachedHandler(t, config).ChangelogFromFile(first, createChangelogFileRequest(t, "../data/openapi-test3.yaml"))
+	require.Equal(t, http.StatusCreated, first.Result().StatusCode)
+	require.NotEmpty(t, first.Result().Header.Get(internal.HeaderETag))
+
+	files := getCacheFiles(t, dir)
+	require.Len(t, files, 1)
+
+	// a new handler starts with an empty memory tier, so the report is read from disk: change it there to tell it apart
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(readFile(t, files[0]), &entry))
+	entry["body"] = []byte(`{"changes":[]}`)
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(files[0], data, 0o600))
+
+	second := httptest.NewRecorder()
+	createCachedHandler(t, config).ChangelogFromFile(second, createChangelogFileRequest(t, "../data/openapi-test3.yaml"))
+	require.Equal(t, http.StatusCreated, second.Result().StatusCode)
+	require.Equal(t, first.Result().Header.Get(internal.HeaderETag), second.Result().Header.Get(internal.HeaderETag))
+	require.Equal(t, `{"changes":[]}`, second.Body.String())
+}
+
+func TestChangelogFromFile_CacheKeyOptions(t *testing.T) {
+
+	dir := t.TempDir()
+	h := createCachedHandler(t, internal.CacheConfig{Dir: dir, DiskSize: 1 << 20})
+
+	for _, query := range []string{"", "?lang=ru", "?fail-on=ERR", "?deprecation-days-beta=30", "?exclude-elements=description", "?format=yaml"} {
+		r := createChangelogFileRequest(t, "../data/openapi-test3.yaml")
+		r.URL.RawQuery = query[min(1, len(query)):]
+		w := httptest.NewRecorder()
+		h.ChangelogFromFile(w, r)
+		require.Contains(t, []int{http.StatusCreated, http.StatusConflict}, w.Result().StatusCode, query)
+	}
+
+	require.Len(t, getCacheFiles(t, dir), 6)
+}
+
+func TestChangelogFromFile_CacheKeyJsonConfig(t *testing.T) {
+
+	dir := t.TempDir()
+	h := createCachedHandler(t, internal.CacheConfig{Dir: dir, DiskSize: 1 << 20})
+
+	for _, config := range []*internal.JsonConfig{nil, {Lang: "ru"}, {Lang: "ru"}} {
+		r := createJsonRequest(t, "/tenants/test-tenant/changelog", internal.JsonRequest{
+			Base:     internal.JsonSpecs{{Base64: base64.StdEncoding.EncodeToString(readFile(t, "../data/openapi-test1.yaml"))}},
+			Revision: internal.JsonSpecs{{Base64: base64.StdEncoding.EncodeToString(readFile(t, "../data/openapi-test3.yaml"))}},
+			Config:   config,
+		})
+		w := httptest.NewRecorder()
+		h.ChangelogFromFile(w, r)
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	}
+
+	require.Len(t, getCacheFiles(t, dir), 2)
+}
+
+func TestChangelogFromFile_CacheDiskSize(t *testing.T) {
+
+	dir := t.TempDir()
+
+	w := httptest.NewRecorder()
+	createCachedHandler(t, internal.CacheConfig{Dir: dir, DiskSize: 1 << 20}).ChangelogFromFile(w, createChangelogFileRequest(t, "../data/openapi-test3.yaml"))
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	files := getCacheFiles(t, dir)
+	require.Len(t, files, 1)
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+
+	// the disk tier only has room for the first report, so caching another one removes it
+	w = httptest.NewRecorder()
+	createCachedHandler(t, internal.CacheConfig{Dir: dir, DiskSize: info.Size()}).ChangelogFromFile(w, createChangelogFileRequest(t, "../data/openapi-test1.yaml"))
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+	remaining := getCacheFiles(t, dir)
+	require.Len(t, remaining, 1)
+	require.NotEqual(t, files[0], remaining[0])
+}
+
+func TestChangelogFromUri_Cached(t *testing.T) {
+
+	revision := &atomic.Value{}
+	revision.Store("openapi-test3.yaml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../data/"+revision.Load().(string))
+	}))
+	defer server.Close()
+
+	createRequest := func() *http.Request {
+		r := createMockRequest(t)
+		r.URL.RawQuery = url.Values{"base": {specUri("openapi-test1.yaml")}, "revision": {server.URL + "/revision.yaml"}}.Encode()
+		r.Header.Set("Accept", internal.HeaderAppJson)
+		return r
+	}
+
+	dir := t.TempDir()
+	config := internal.CacheConfig{Dir: dir, DiskSize: 1 << 20}
+
+	first := httptest.NewRecorder()
+	createCachedHandler(t, config).ChangelogFromUri(first, createRequest())
+	require.Equal(t, http.StatusCreated, first.Result().StatusCode)
+
+	files := getCacheFiles(t, dir)
+	require.Len(t, files, 1)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(readFile(t, files[0]), &entry))
+	entry["body"] = []byte(`{"changes":[]}`)
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(files[0], data, 0o600))
+
+	// the same content is reported from the cache
+	second := httptest.NewRecorder()
+	createCachedHandler(t, config).ChangelogFromUri(second, createRequest())
+	require.Equal(t, `{"changes":[]}`, second.Body.String())
+
+	// changed content behind the same URI is reported anew
+	revision.Store("openapi-test1.yaml")
+	third := httptest.NewRecorder()
+	createCachedHandler(t, config).ChangelogFromUri(third, createRequest())
+	require.NotEqual(t, first.Body.String(), third.Body.String())
+	require.Len(t, getCacheFiles(t, dir), 2)
+}
+
+func TestChangelogFromFile_ArchiveCached(t *testing.T) {
+
+	dir := t.TempDir()
+	h := createCachedHandler(t, internal.CacheConfig{Dir: dir, DiskSize: 1 << 20})
+
+	base := createZip(t, map[string]string{"openapi.yaml": archiveSpec, "schemas/user.yaml": "type: object\n"})
+	changed := createZip(t, map[string]string{"openapi.yaml": archiveSpec, "schemas/user.yaml": "type: string\n"})
+
+	for _, revision := range [][]byte{base, base, changed} {
+		w := httptest.NewRecorder()
+		h.ChangelogFromFile(w, createMultipartRequest(t, "/changelog", base, revision))
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	}
+
+	require.Len(t, getCacheFiles(t, dir), 2)
+}
+
+func TestChangelogFromFile_CacheKeyLimits(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// a report is cached per limits, so a tenant with lower limits is never served a report of specs which exceed them
+	for _, maxArchiveFiles := range []int{1000, 10} {
+		cache, err := internal.NewCache(internal.CacheConfig{Dir: dir, DiskSize: 1 << 20})
+		require.NoError(t, err)
+		limits := internal.NewLimits()
+		limits.MaxArchiveFiles = maxArchiveFiles
+		w := httptest.NewRecorder()
+		internal.NewHandler(ds.NewInMemoryClient(nil), nil, limits, createFetcher(), cache).ChangelogFromFile(w, createChangelogFileRequest(t, "../data/openapi-test3.yaml"))
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	}
+
+	require.Len(t, getCacheFiles(t, dir), 2)
+}
+
+func TestChangelogFromFile_ProblemNotCached(t *testing.T) {
+
+	dir := t.TempDir()
+	r := createMultipartRequest(t, "/tenants/test-tenant/changelog", []byte("not a spec"), readFile(t, "../data/openapi-test3.yaml"))
+	w := httptest.NewRecorder()
+	createCachedHandler(t, internal.CacheConfig{Dir: dir, DiskSize: 1 << 20}).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Empty(t, w.Result().Header.Get(internal.HeaderETag))
+	require.Empty(t, getCacheFiles(t, dir))
+}
+
+func TestChangelogFromUri_IfNoneMatch(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).ChangelogFromUri(w, createBreakingChangesUriRequest(t, url.Values{}))
+	etag := w.Result().Header.Get(internal.HeaderETag)
+	require.NotEmpty(t, etag)
+
+	r := createBreakingChangesUriRequest(t, url.Values{})
+	r.Header.Set(internal.HeaderIfNoneMatch, `"other", `+etag)
+	w = httptest.NewRecorder()
+	createHandler(t).ChangelogFromUri(w, r)
+
+	require.Equal(t, http.StatusNotModified, w.Result().StatusCode)
+	require.Equal(t, etag, w.Result().Header.Get(internal.HeaderETag))
+	require.Empty(t, w.Body.String())
+
+	r = createBreakingChangesUriRequest(t, url.Values{"lang": {"ru"}})
+	r.Header.Set(internal.HeaderIfNoneMatch, etag)
+	w = httptest.NewRecorder()
+	createHandler(t).ChangelogFromUri(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.NotEqual(t, etag, w.Result().Header.Get(internal.HeaderETag))
+}
+
+func TestChangelogFromFile_IfNoneMatch(t *testing.T) {
+
+	h := createCachedHandler(t, internal.CacheConfig{MemorySize: 1 << 20})
+
+	w := httptest.NewRecorder()
+	h.ChangelogFromFile(w, createChangelogFileRequest(t, "../data/openapi-test3.yaml"))
+	etag := w.Result().Header.Get(internal.HeaderETag)
+
+	r := createChangelogFileRequest(t, "../data/openapi-test3.yaml")
+	r.Header.Set(internal.HeaderIfNoneMatch, etag)
+	w = httptest.NewRecorder()
+	h.ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypePreconditionFailed, decodeProblem(t, w).Type)
+}
@@ -3,85 +3,95 @@ package internal
 import (
 	"fmt"
 	"net/http"
-	"os"
 
-	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/oasdiff/oasdiff/checker"
 	"github.com/oasdiff/oasdiff/diff"
 	"github.com/oasdiff/oasdiff/formatters"
 	"github.com/oasdiff/oasdiff/load"
-	log "github.com/sirupsen/logrus"
 )
 
 const CHANGELOG_LEVEL = checker.INFO
 
 func (h *Handler) ChangelogFromUri(w http.ResponseWriter, r *http.Request) {
 
-	base := GetQueryString(r, "base", "")
-	if base == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	h.writeReport(w, r, reportChangelog)
+}
 
-	revision := GetQueryString(r, "revision", "")
-	if revision == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+func (h *Handler) ChangelogFromFile(w http.ResponseWriter, r *http.Request) {
 
-	getChangelog(w, r, base, revision, CHANGELOG_LEVEL)
+	h.writeReport(w, r, reportChangelog)
 }
 
-func (h *Handler) ChangelogFromFile(w http.ResponseWriter, r *http.Request) {
+// getChangelog writes the changes up to the level, without those matched by the request's ignore file.
+// The status is 409 Conflict instead of 201 Created if changes at or above the fail-on level remain.
+// The tenant's template named by the template option replaces the built-in formats.
+func (h *Handler) getChangelog(w http.ResponseWriter, r *http.Request, specs *Specs, options *Options, level checker.Level) {
 
-	dir, base, revision, err := CreateFiles(r)
+	tmpl, err := h.getRequestTemplate(r, options)
 	if err != nil {
-		log.Errorf("failed to create files with %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, err)
 		return
 	}
-	defer CloseFile(base)
-	defer CloseFile(revision)
-	defer os.RemoveAll(dir)
 
-	getChangelog(w, r, base.Name(), revision.Name(), CHANGELOG_LEVEL)
-}
+	contentType, err := getChangelogContentType(r, options, tmpl)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	ignoreFiles, err := readIgnoreFiles(options, h.getLimits(r), h.fetcher)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
 
-func getChangelog(w http.ResponseWriter, r *http.Request, base string, revision string, level checker.Level) {
-	specInfoPair, err := getSpecInfoPair(base, revision)
+	changes, err := h.calcChangelog(r, specs, options, level)
 	if err != nil {
-		log.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, err)
 		return
 	}
 
-	changes, err := calcChangelog(r, specInfoPair, level)
+	languageCode, err := negotiateLanguage(r, options.Lang, h.getTenantSettings(r).Language)
 	if err != nil {
-		log.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, err)
 		return
 	}
 
-	contentType := getContentType(GetAcceptHeader(r))
-	languageCode := GetLanguageCode(GetAcceptLanguageHeader(r))
+	for _, input := range ignoreInputs {
+		if ignoreFile, ok := ignoreFiles[input.name]; ok {
+			var matched, unused []int
+			changes, matched, unused = ignoreFile.Filter(changes, checker.NewLocalizer(languageCode))
+			writeIgnoreHeaders(w, input, matched, unused)
+		}
+	}
 
-	out, err := getChangelogOutput(changes, contentType, specInfoPair, languageCode)
+	var out []byte
+	if tmpl != nil {
+		out, err = tmpl.render(changes, specs.Pair(), languageCode)
+	} else if out, err = getChangelogOutput(changes, contentType, specs.Pair(), languageCode); err != nil {
+		err = NewProblem(http.StatusInternalServerError, ProblemTypeRenderFailed, err.Error())
+	}
 	if err != nil {
-		log.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	writeLevelCountHeaders(w, newChangesSummary(changes))
 	w.Header().Set(HeaderContentType, contentType)
+	w.Header().Set(HeaderContentLanguage, languageCode)
+	w.Header().Set(HeaderVary, HeaderAccept+", "+HeaderAcceptLanguage)
+	w.WriteHeader(getChangesStatus(changes, options.FailOn))
 	_, _ = w.Write(out)
 }
 
-func getContentType(acceptHeader string) string {
-	if acceptHeader == "" || acceptHeader == "*/*" {
-		return HeaderAppJson
+// getChangelogContentType returns the media type of the template, or else negotiates one of the built-in formats
+func getChangelogContentType(r *http.Request, options *Options, tmpl *Template) (string, error) {
+
+	if tmpl != nil {
+		return tmpl.MediaType, nil
 	}
-	return acceptHeader
+
+	return negotiateContentType(r, options.Format, changelogMediaTypes)
 }
 
 func getChangelogOutput(changes checker.Changes, contentType string, specInfoPair *load.SpecInfoPair, languageCode string) ([]byte, error) {
@@ -129,34 +139,34 @@ func getChangelogOutput(changes checker.Changes, contentType string, specInfoPai
 			return nil, fmt.Errorf("failed to markdown encode 'breaking-changes' report with '%v'", err)
 		}
 		return out, nil
+	case HeaderAppSarif:
+		return getSarifOutput(changes, localizer, getRevisionFile(specInfoPair))
+	case HeaderAppJUnit:
+		return getJUnitOutput(changes, localizer, getRevisionFile(specInfoPair))
+	case HeaderTextGitHubActions:
+		return getGitHubActionsOutput(changes, localizer, getRevisionFile(specInfoPair)), nil
+	case HeaderAppGitLab:
+		return getGitLabOutput(changes, localizer, getRevisionFile(specInfoPair))
 	default:
 		return nil, fmt.Errorf("unsupported content type '%v'", contentType)
 	}
 }
 
-func getSpecInfoPair(base string, revision string) (*load.SpecInfoPair, error) {
-	loader := openapi3.NewLoader()
-	loader.IsExternalRefsAllowed = true
+// calcChangelog returns the changes up to the level, the tenant's deprecation policy applies unless overridden by the request
+func (h *Handler) calcChangelog(r *http.Request, specs *Specs, options *Options, level checker.Level) (checker.Changes, error) {
 
-	s1, err := load.NewSpecInfo(loader, load.NewSource(base))
-	if err != nil {
-		return nil, fmt.Errorf("failed to load base spec from %q with %v", base, err)
-	}
-	s2, err := load.NewSpecInfo(loader, load.NewSource(revision))
+	diffReport, operationsSources, err := specs.Diff(options.Diff)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load revision spec from %q with %v", revision, err)
+		return nil, err
 	}
 
-	return load.NewSpecInfoPair(s1, s2), nil
+	return h.checkChanges(r, diffReport, operationsSources, options, level), nil
 }
 
-func calcChangelog(r *http.Request, specInfoPair *load.SpecInfoPair, level checker.Level) (checker.Changes, error) {
+// checkChanges returns the changes of the diff up to the level, with the checker config of the options
+func (h *Handler) checkChanges(r *http.Request, diffReport *diff.Diff, operationsSources *diff.OperationsSourcesMap, options *Options, level checker.Level) checker.Changes {
 
-	diffReport, operationsSources, err := diff.GetWithOperationsSourcesMap(
-		CreateConfig(r), specInfoPair.Base, specInfoPair.Revision)
-	if err != nil {
-		return nil, fmt.Errorf("failed to 'diff.GetWithOperationsSourcesMap' with %v", err)
-	}
+	checkerConfig := options.NewCheckerConfig(h.getTenantSettings(r).getDeprecationDays())
 
-	return checker.CheckBackwardCompatibilityUntilLevel(checker.NewConfig(checker.GetAllChecks()), diffReport, operationsSources, level), nil
+	return checker.CheckBackwardCompatibilityUntilLevel(checkerConfig, diffReport, operationsSources, level)
 }
@@ -43,10 +43,50 @@ func TestChangelog(t *testing.T) {
 	r.Header.Set("User-Agent", headerUserAgent)
 	w := httptest.NewRecorder()
 
-	internal.NewHandler().ChangelogFromFile(w, r)
+	createHandler(t).ChangelogFromFile(w, r)
 
 	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
 	var report map[string][]formatters.Change
 	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&report))
 	require.True(t, len(report["changes"]) > 0)
 }
+
+func TestChangelogFromUri_InvalidSpec(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", specUri("no-such-spec.yaml"))
+	q.Add("revision", specUri("openapi-test3.yaml"))
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeSpecLoadFailed, problem.Type)
+	require.Equal(t, internal.InputBase, problem.Input)
+}
+
+func TestChangelogFromFile_NoRevision(t *testing.T) {
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	basePart, err := writer.CreateFormFile("base", "openapi-test1.yaml")
+	require.NoError(t, err)
+	_, err = basePart.Write(readFile(t, "../data/openapi-test1.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	r, err := http.NewRequest(http.MethodPost, "/changelog", body)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeMissingParameter, problem.Type)
+	require.Equal(t, internal.InputRevision, problem.Input)
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/oasdiff/oasdiff/checker"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ParamSeverity overrides the level of checks, given as '<check-id>:<level>'
+	ParamSeverity = "severity"
+	// ParamDisable disables checks by id
+	ParamDisable = "disable"
+	// InputChecks is the name of the multipart part holding a CheckerConfig
+	InputChecks = "checks"
+	// ParamDeprecationDaysBeta is the min number of days between deprecating a beta endpoint and its sunset
+	ParamDeprecationDaysBeta = "deprecation-days-beta"
+	// ParamDeprecationDaysStable is the min number of days between deprecating a stable endpoint and its sunset
+	ParamDeprecationDaysStable = "deprecation-days-stable"
+)
+
+// DeprecationDays is a deprecation policy: the min number of days between deprecating an endpoint and its sunset, by stability level.
+// Deprecating an endpoint with an earlier sunset, or without one, is reported as a breaking change.
+type DeprecationDays struct {
+	Beta   uint
+	Stable uint
+}
+
+// CheckerConfig configures the checks of a request, it is given as a JSON or YAML part or as part of a JSON request, see docs/openapi.yaml
+type CheckerConfig struct {
+	Severity map[string]string `json:"severity,omitempty" yaml:"severity,omitempty"` // check id to level: err, warn, info or none
+	Disabled []string          `json:"disabled,omitempty" yaml:"disabled,omitempty"` // check ids
+
+	DeprecationDaysBeta   *uint `json:"deprecation-days-beta,omitempty" yaml:"deprecation-days-beta,omitempty"`
+	DeprecationDaysStable *uint `json:"deprecation-days-stable,omitempty" yaml:"deprecation-days-stable,omitempty"`
+}
+
+// values returns the config as query parameters
+func (c *CheckerConfig) values() url.Values {
+
+	res := url.Values{}
+	if c == nil {
+		return res
+	}
+
+	for id, level := range c.Severity {
+		res.Add(ParamSeverity, id+":"+level)
+	}
+	if len(c.Disabled) > 0 {
+		res[ParamDisable] = c.Disabled
+	}
+	if c.DeprecationDaysBeta != nil {
+		res.Set(ParamDeprecationDaysBeta, strconv.FormatUint(uint64(*c.DeprecationDaysBeta), 10))
+	}
+	if c.DeprecationDaysStable != nil {
+		res.Set(ParamDeprecationDaysStable, strconv.FormatUint(uint64(*c.DeprecationDaysStable), 10))
+	}
+
+	return res
+}
+
+// readCheckerConfigPart reads a JSON or YAML checker config part and adds it to the options of the body, so it applies exactly like query parameters
+func readCheckerConfigPart(reader io.Reader, maxSize int64, options *bodyOptions) error {
+
+	data, err := readSpecData(reader, InputChecks, maxSize)
+	if err != nil {
+		return err
+	}
+
+	var config CheckerConfig
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("failed to parse checker config with %v", err)).WithInput(InputChecks)
+	}
+
+	return options.add(config.values())
+}
+
+// getCheckLevels returns the levels of checks overridden by the options.
+// The 'severity' option sets the level of a check as '<check-id>:<level>' and the 'disable' option disables checks, both may be repeated or comma separated.
+func getCheckLevels(values url.Values) (map[string]checker.Level, error) {
+
+	ruleIds := checker.GetAllRuleIds()
+	res := map[string]checker.Level{}
+
+	for _, severity := range getListOption(values, ParamSeverity) {
+		id, levelName, found := strings.Cut(severity, ":")
+		if !found {
+			return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("invalid severity '%s', use '<check-id>:<level>'", severity)).WithInput(ParamSeverity)
+		}
+		if !slices.Contains(ruleIds, id) {
+			return nil, newUnknownCheckProblem(id, ParamSeverity)
+		}
+		level, err := checker.NewLevel(strings.ToLower(levelName))
+		if err != nil {
+			return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("invalid level '%s' of check '%s', use err, warn, info or none", levelName, id)).WithInput(ParamSeverity)
+		}
+		res[id] = level
+	}
+
+	// a disabled check has no level, so it is never reported
+	for _, id := range getListOption(values, ParamDisable) {
+		if !slices.Contains(ruleIds, id) {
+			return nil, newUnknownCheckProblem(id, ParamDisable)
+		}
+		res[id] = checker.NONE
+	}
+
+	return res, nil
+}
+
+func newUnknownCheckProblem(id string, input string) *Problem {
+	return NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("unknown check id '%s'", id)).WithInput(input)
+}
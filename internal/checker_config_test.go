@@ -0,0 +1,169 @@
+package internal_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func createBreakingChangesUriRequest(t *testing.T, query url.Values) *http.Request {
+
+	r := createMockRequest(t)
+	query.Set("base", specUri("openapi-test1.yaml"))
+	query.Set("revision", specUri("openapi-test3.yaml"))
+	r.URL.RawQuery = query.Encode()
+	r.Header.Set("Accept", internal.HeaderAppJson)
+
+	return r
+}
+
+func TestCreateCheckerConfig_Severity(t *testing.T) {
+
+	r := createMockRequest(t)
+	r.URL.RawQuery = "severity=request-parameter-removed:ERR,response-success-status-removed:info&disable=api-path-removed-without-deprecation"
+
+	options, err := internal.NewOptions(r.URL.Query())
+	require.NoError(t, err)
+	config := options.NewCheckerConfig(internal.DeprecationDays{})
+	require.Equal(t, "error", config.LogLevels["request-parameter-removed"].String())
+	require.Equal(t, "info", config.LogLevels["response-success-status-removed"].String())
+	require.Equal(t, 0, int(config.LogLevels["api-path-removed-without-deprecation"]))
+}
+
+func TestCreateCheckerConfig_DeprecationDays(t *testing.T) {
+
+	r := createMockRequest(t)
+	r.URL.RawQuery = "deprecation-days-beta=30"
+
+	options, err := internal.NewOptions(r.URL.Query())
+	require.NoError(t, err)
+	config := options.NewCheckerConfig(internal.DeprecationDays{Beta: 10, Stable: 180})
+	require.Equal(t, uint(30), config.MinSunsetBetaDays)
+	require.Equal(t, uint(180), config.MinSunsetStableDays)
+}
+
+func TestCreateCheckerConfig_InvalidDeprecationDays(t *testing.T) {
+
+	r := createMockRequest(t)
+	r.URL.RawQuery = "deprecation-days-stable=-1"
+
+	_, err := internal.NewOptions(r.URL.Query())
+	var problem *internal.Problem
+	require.ErrorAs(t, err, &problem)
+	require.Equal(t, internal.ParamDeprecationDaysStable, problem.Input)
+}
+
+func TestBreakingChangesFromFile_TenantDeprecationDays(t *testing.T) {
+
+	const spec = `openapi: 3.0.0
+info:
+  title: deprecation
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      deprecated: %t
+      responses:
+        '200':
+          description: OK
+`
+
+	for settings, breaking := range map[internal.TenantSettings]bool{
+		{}:                           false,
+		{DeprecationDaysStable: 180}: true,
+	} {
+		r := createMultipartRequest(t, "/breaking-changes", []byte(fmt.Sprintf(spec, false)), []byte(fmt.Sprintf(spec, true)))
+		r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant"})
+		w := httptest.NewRecorder()
+
+		internal.NewHandler(settingsClient{settings: settings}, nil, internal.NewLimits(), createFetcher(), nil).BreakingChangesFromFile(w, r)
+
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		require.Equal(t, breaking, strings.Contains(w.Body.String(), "api-deprecated-sunset-missing"))
+	}
+}
+
+func TestBreakingChangesFromUri_Disable(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{"disable": {"request-parameter-removed"}}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.NotContains(t, w.Body.String(), "request-parameter-removed")
+	require.Contains(t, w.Body.String(), "response-success-status-removed")
+}
+
+func TestBreakingChangesFromUri_SeverityBelowBreaking(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{"severity": {"response-success-status-removed:info"}}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.NotContains(t, w.Body.String(), "response-success-status-removed")
+}
+
+func TestBreakingChangesFromUri_UnknownCheck(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{"severity": {"no-such-check:err"}}))
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeInvalidParameter, problem.Type)
+	require.Equal(t, "severity", problem.Input)
+}
+
+func TestBreakingChangesFromFile_ChecksPart(t *testing.T) {
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, data := range map[string][]byte{
+		"base":     readFile(t, "../data/openapi-test1.yaml"),
+		"revision": readFile(t, "../data/openapi-test3.yaml"),
+		"checks":   []byte("severity:\n  response-success-status-removed: none\ndisabled:\n  - request-parameter-removed\n"),
+	} {
+		part, err := writer.CreateFormFile(name, name+".yaml")
+		require.NoError(t, err)
+		_, err = part.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	r, err := http.NewRequest(http.MethodPost, "/breaking-changes", body)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.JSONEq(t, `{"changes":[]}`, w.Body.String())
+}
+
+func TestBreakingChangesFromFile_JsonChecks(t *testing.T) {
+
+	r := createJsonRequest(t, "/breaking-changes", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision: internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+		Checks:   &internal.CheckerConfig{Disabled: []string{"response-success-status-removed"}},
+	})
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.NotContains(t, w.Body.String(), "response-success-status-removed")
+	require.Contains(t, w.Body.String(), "request-parameter-removed")
+}
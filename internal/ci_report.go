@@ -0,0 +1,289 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/oasdiff/oasdiff/checker"
+	"github.com/oasdiff/oasdiff/load"
+)
+
+// media types of the reports which CI systems consume
+const (
+	HeaderAppSarif          = "application/sarif+json"
+	HeaderAppJUnit          = "application/junit+xml"
+	HeaderTextGitHubActions = "text/x-github-actions"
+	HeaderAppGitLab         = "application/vnd.gitlab.codequality+json"
+)
+
+const (
+	oasdiffName = "oasdiff"
+	oasdiffUri  = "https://github.com/oasdiff/oasdiff"
+	sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// ciLevels are the severities of the CI reports per level: SARIF, GitHub Actions and GitLab Code Quality
+var ciLevels = map[checker.Level]struct{ sarif, github, gitlab string }{
+	checker.ERR:  {sarif: "error", github: "error", gitlab: "critical"},
+	checker.WARN: {sarif: "warning", github: "warning", gitlab: "minor"},
+	checker.INFO: {sarif: "note", github: "notice", gitlab: "info"},
+}
+
+// getChangeLocation returns where the change is in the API, the operation and path, or the section for changes of components and security
+func getChangeLocation(change checker.Change) string {
+
+	if location := strings.TrimSpace(change.GetOperation() + " " + change.GetPath()); location != "" {
+		return location
+	}
+
+	return change.GetSection()
+}
+
+// getRevisionFile returns the name or URI of the revision spec, composed revisions have no single one
+func getRevisionFile(specInfoPair *load.SpecInfoPair) string {
+
+	if specInfoPair == nil || specInfoPair.Revision == nil || specInfoPair.Revision.Url == "" {
+		return InputRevision
+	}
+
+	return specInfoPair.Revision.Url
+}
+
+// getChangeFile returns the name or URI of the spec of the change's operation, or else the revision file
+func getChangeFile(change checker.Change, revisionFile string) string {
+
+	if source := change.GetSource(); source != "" {
+		return source
+	}
+
+	return revisionFile
+}
+
+// getChangeLine returns the 1-based line of the change in its spec, or 1 if oasdiff did not locate it
+func getChangeLine(change checker.Change) int {
+
+	return change.GetSourceLine() + 1
+}
+
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+// getSarifOutput renders the changes as a SARIF 2.1.0 log for code scanning, with one rule per check
+func getSarifOutput(changes checker.Changes, localizer checker.Localizer, revisionFile string) ([]byte, error) {
+
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: oasdiffName, InformationUri: oasdiffUri, Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+
+	ruleIndexes := map[string]int{}
+	for _, change := range changes {
+		ruleIndex, ok := ruleIndexes[change.GetId()]
+		if !ok {
+			ruleIndex = len(run.Tool.Driver.Rules)
+			ruleIndexes[change.GetId()] = ruleIndex
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{Id: change.GetId()})
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleId:    change.GetId(),
+			RuleIndex: ruleIndex,
+			Level:     ciLevels[change.GetLevel()].sarif,
+			Message:   sarifMessage{Text: change.GetUncolorizedText(localizer)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{Uri: getChangeFile(change, revisionFile)},
+					Region:           sarifRegion{StartLine: getChangeLine(change)},
+				},
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: getChangeLocation(change)}},
+			}},
+		})
+	}
+
+	out, err := json.Marshal(sarifReport{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sarif encode 'breaking-changes' report with '%v'", err)
+	}
+
+	return out, nil
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// getJUnitOutput renders the changes as a JUnit XML test suite with a test case per change, named by its check and classed by its location.
+// Changes of levels ERR and WARN are failures of their level's type, INFO changes pass so they show up in test tabs without failing builds.
+func getJUnitOutput(changes checker.Changes, localizer checker.Localizer, revisionFile string) ([]byte, error) {
+
+	suite := junitTestSuite{Name: oasdiffName, Tests: len(changes), TestCases: []junitTestCase{}}
+	for _, change := range changes {
+		text := change.GetUncolorizedText(localizer)
+		testCase := junitTestCase{
+			Name:      change.GetId(),
+			Classname: getChangeLocation(change),
+			File:      getChangeFile(change, revisionFile),
+		}
+		if change.GetLevel() == checker.INFO {
+			testCase.SystemOut = text
+		} else {
+			testCase.Failure = &junitFailure{Type: change.GetLevel().String(), Message: text, Text: text}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	out, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to junit encode 'breaking-changes' report with '%v'", err)
+	}
+
+	return append([]byte(xml.Header), out...), nil
+}
+
+// getGitHubActionsOutput renders the changes as GitHub Actions workflow commands, one annotation line per change.
+// Unlike oasdiff's formatter it never writes the job output file, the counts are in the X-Oasdiff-* headers instead.
+func getGitHubActionsOutput(changes checker.Changes, localizer checker.Localizer, revisionFile string) []byte {
+
+	var res strings.Builder
+	for _, change := range changes {
+		res.WriteString(fmt.Sprintf("::%s file=%s,line=%d,title=%s::%s\n",
+			ciLevels[change.GetLevel()].github,
+			escapeGitHubProperty(getChangeFile(change, revisionFile)),
+			getChangeLine(change),
+			escapeGitHubProperty(change.GetId()),
+			escapeGitHubData(getChangeLocation(change)+" "+change.GetUncolorizedText(localizer))))
+	}
+
+	return []byte(res.String())
+}
+
+func escapeGitHubData(s string) string {
+
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGitHubProperty(s string) string {
+
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeGitHubData(s))
+}
+
+type gitLabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitLabLocation `json:"location"`
+}
+
+type gitLabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitLabLines `json:"lines"`
+}
+
+type gitLabLines struct {
+	Begin int `json:"begin"`
+}
+
+// getGitLabOutput renders the changes as a GitLab Code Quality report.
+// Fingerprints identify a change by its check, spec, location and text, so GitLab can tell new changes from those of the target branch.
+func getGitLabOutput(changes checker.Changes, localizer checker.Localizer, revisionFile string) ([]byte, error) {
+
+	issues := make([]gitLabIssue, len(changes))
+	for i, change := range changes {
+		text := change.GetUncolorizedText(localizer)
+		fingerprint := sha256.Sum256([]byte(strings.Join([]string{change.GetId(), getChangeFile(change, revisionFile), getChangeLocation(change), text}, "\n")))
+		issues[i] = gitLabIssue{
+			Description: getChangeLocation(change) + " " + text,
+			CheckName:   change.GetId(),
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+			Severity:    ciLevels[change.GetLevel()].gitlab,
+			Location:    gitLabLocation{Path: getChangeFile(change, revisionFile), Lines: gitLabLines{Begin: getChangeLine(change)}},
+		}
+	}
+
+	out, err := json.Marshal(issues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gitlab encode 'breaking-changes' report with '%v'", err)
+	}
+
+	return out, nil
+}
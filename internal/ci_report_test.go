@@ -0,0 +1,157 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakingChangesFromUri_Sarif(t *testing.T) {
+
+	r := createBreakingChangesUriRequest(t, url.Values{})
+	r.Header.Set("Accept", internal.HeaderAppSarif)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromUri(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderAppSarif, w.Result().Header.Get("Content-Type"))
+
+	var report struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						Id string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleId    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							Uri string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+					LogicalLocations []struct {
+						FullyQualifiedName string `json:"fullyQualifiedName"`
+					} `json:"logicalLocations"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Equal(t, "2.1.0", report.Version)
+	require.Len(t, report.Runs, 1)
+
+	run := report.Runs[0]
+	require.NotEmpty(t, run.Results)
+	for _, result := range run.Results {
+		require.Equal(t, run.Tool.Driver.Rules[result.RuleIndex].Id, result.RuleId)
+		require.Contains(t, []string{"error", "warning"}, result.Level)
+		require.True(t, strings.HasSuffix(result.Locations[0].PhysicalLocation.ArtifactLocation.Uri, "openapi-test3.yaml"))
+		require.NotEmpty(t, result.Locations[0].LogicalLocations[0].FullyQualifiedName)
+	}
+}
+
+func TestChangelogFromUri_JUnit(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).ChangelogFromUri(w, createBreakingChangesUriRequest(t, url.Values{"format": {"junit"}}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderAppJUnit, w.Result().Header.Get("Content-Type"))
+
+	var suite struct {
+		Tests     int `xml:"tests,attr"`
+		Failures  int `xml:"failures,attr"`
+		TestCases []struct {
+			Name      string `xml:"name,attr"`
+			Classname string `xml:"classname,attr"`
+			Failure   *struct {
+				Type string `xml:"type,attr"`
+			} `xml:"failure"`
+		} `xml:"testcase"`
+	}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &suite))
+	require.Len(t, suite.TestCases, suite.Tests)
+	require.Less(t, suite.Failures, suite.Tests, "info changes don't fail")
+
+	failures := 0
+	for _, testCase := range suite.TestCases {
+		require.NotEmpty(t, testCase.Name)
+		require.NotEmpty(t, testCase.Classname)
+		if testCase.Failure != nil {
+			require.Contains(t, []string{"error", "warning"}, testCase.Failure.Type)
+			failures++
+		}
+	}
+	require.Equal(t, suite.Failures, failures)
+}
+
+func TestBreakingChangesFromUri_GitHubActions(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{"format": {"github"}}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderTextGitHubActions, w.Result().Header.Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "::error file=")
+	require.Contains(t, w.Body.String(), ",title=response-success-status-removed::GET /api/{domain}/{project}/badges/security-score ")
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		require.Regexp(t, `^::(error|warning) file=[^,]+,line=\d+,title=[a-z-]+::\S`, line)
+	}
+}
+
+func TestBreakingChangesFromUri_GitLab(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{"format": {"gitlab"}}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderAppGitLab, w.Result().Header.Get("Content-Type"))
+
+	var issues []struct {
+		CheckName   string `json:"check_name"`
+		Fingerprint string `json:"fingerprint"`
+		Severity    string `json:"severity"`
+		Location    struct {
+			Path  string `json:"path"`
+			Lines struct {
+				Begin int `json:"begin"`
+			} `json:"lines"`
+		} `json:"location"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issues))
+	require.NotEmpty(t, issues)
+
+	fingerprints := map[string]bool{}
+	for _, issue := range issues {
+		require.NotEmpty(t, issue.CheckName)
+		require.Contains(t, []string{"critical", "minor"}, issue.Severity)
+		require.NotEmpty(t, issue.Location.Path)
+		require.Equal(t, 1, issue.Location.Lines.Begin)
+		require.False(t, fingerprints[issue.Fingerprint], "fingerprints are unique")
+		fingerprints[issue.Fingerprint] = true
+	}
+}
+
+func TestDiffFromUri_CIFormatUnsupported(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).DiffFromUri(w, createBreakingChangesUriRequest(t, url.Values{"format": {"sarif"}}))
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.ParamFormat, decodeProblem(t, w).Input)
+}
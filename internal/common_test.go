@@ -1,10 +1,17 @@
 package internal_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 
+	"github.com/oasdiff/go-common/ds"
 	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/oasdiff/oasdiff-service/internal/badge"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,6 +29,69 @@ func TestCreateConfig_PathFilter(t *testing.T) {
 	require.Equal(t, expected, config.MatchPath)
 }
 
+func TestCreateConfig_ExcludeElements(t *testing.T) {
+
+	r := createMockRequest(t)
+	r.URL.RawQuery = "exclude-elements=examples,extensions&include-path-params=true"
+
+	options, err := internal.NewOptions(r.URL.Query())
+	require.NoError(t, err)
+	config := options.Diff
+	require.True(t, config.IsExcludeExamples())
+	require.True(t, config.IsExcludeExtensions())
+	require.False(t, config.IsExcludeEndpoints())
+	require.True(t, config.IncludePathParams)
+}
+
+func TestCreateConfig_Invalid(t *testing.T) {
+
+	for query, input := range map[string]string{
+		"exclude-elements=no-such-element": "exclude-elements",
+		"include-path-params=maybe":        "include-path-params",
+		"unmatch-path=(":                   "unmatch-path",
+	} {
+		r := createMockRequest(t)
+		r.URL.RawQuery = query
+
+		_, err := internal.NewOptions(r.URL.Query())
+		var problem *internal.Problem
+		require.ErrorAs(t, err, &problem)
+		require.Equal(t, input, problem.Input)
+	}
+}
+
+// specServer serves the test specs over HTTP
+var specServer *httptest.Server
+
+func TestMain(m *testing.M) {
+
+	specServer = httptest.NewServer(http.FileServer(http.Dir("../data")))
+	code := m.Run()
+	specServer.Close()
+	os.Exit(code)
+}
+
+func specUri(name string) string {
+	return specServer.URL + "/" + name
+}
+
+func createHandler(t *testing.T) *internal.Handler {
+
+	bg, err := badge.NewDefaultGenerator(11)
+	require.NoError(t, err)
+
+	return internal.NewHandler(ds.NewInMemoryClient(nil), bg, internal.NewLimits(), createFetcher(), nil)
+}
+
+// createFetcher returns a fetcher which may access the local spec server
+func createFetcher() *internal.Fetcher {
+
+	config := internal.NewFetcherConfig()
+	config.AllowPrivateNetworks = true
+
+	return internal.NewFetcher(config)
+}
+
 func createMockRequest(t *testing.T) *http.Request {
 
 	res, err := http.NewRequest("GET", "/", nil)
@@ -29,3 +99,45 @@ func createMockRequest(t *testing.T) *http.Request {
 
 	return res
 }
+
+func createMultipartRequest(t *testing.T, path string, base []byte, revision []byte) *http.Request {
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	basePart, err := writer.CreateFormFile("base", "base.yaml")
+	require.NoError(t, err)
+	_, err = basePart.Write(base)
+	require.NoError(t, err)
+
+	revisionPart, err := writer.CreateFormFile("revision", "revision.yaml")
+	require.NoError(t, err)
+	_, err = revisionPart.Write(revision)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	r, err := http.NewRequest(http.MethodPost, path, body)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return r
+}
+
+func readFile(t *testing.T, name string) []byte {
+
+	res, err := os.ReadFile(name)
+	require.NoError(t, err)
+
+	return res
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) internal.Problem {
+
+	require.Equal(t, internal.HeaderAppProblemJson, w.Result().Header.Get(internal.HeaderContentType))
+
+	var res internal.Problem
+	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&res))
+	require.Equal(t, w.Result().StatusCode, res.Status)
+
+	return res
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/oasdiff/oasdiff/diff"
+	"github.com/oasdiff/oasdiff/load"
+)
+
+// Specs are the loaded specs of a request.
+// In composed mode each side may consist of several specs, their paths are merged per side before diffing like the oasdiff CLI's composed mode.
+type Specs struct {
+	Base     []*load.SpecInfo
+	Revision []*load.SpecInfo
+	Composed bool
+}
+
+// Pair returns the base and revision specs, composed specs have no single pair and no version info
+func (s *Specs) Pair() *load.SpecInfoPair {
+
+	if s.Composed {
+		return nil
+	}
+
+	return load.NewSpecInfoPair(s.Base[0], s.Revision[0])
+}
+
+// Diff calculates the diff between the base and revision specs
+func (s *Specs) Diff(config *diff.Config) (*diff.Diff, *diff.OperationsSourcesMap, error) {
+
+	if !s.Composed {
+		diffReport, operationsSources, err := diff.GetWithOperationsSourcesMap(config, s.Base[0], s.Revision[0])
+		if err != nil {
+			return nil, nil, NewProblem(http.StatusBadRequest, ProblemTypeDiffFailed, fmt.Sprintf("failed to 'diff.GetWithOperationsSourcesMap' with %v", err))
+		}
+		return diffReport, operationsSources, nil
+	}
+
+	diffReport, operationsSources, err := diff.GetPathsDiff(config, s.Base, s.Revision)
+	if err != nil {
+		if isPathConflict(err) {
+			return nil, nil, NewProblem(http.StatusUnprocessableEntity, ProblemTypePathConflict, err.Error()).WithInput(s.getConflictingInput(config))
+		}
+		return nil, nil, NewProblem(http.StatusBadRequest, ProblemTypeDiffFailed, fmt.Sprintf("failed to 'diff.GetPathsDiff' with %v", err))
+	}
+
+	return diffReport, operationsSources, nil
+}
+
+// isPathConflict tells whether a composed diff failed because an endpoint is defined by more than one spec of the same side, or its x-since-date is invalid
+func isPathConflict(err error) bool {
+	return strings.HasPrefix(err.Error(), "duplicate endpoint") || strings.Contains(err.Error(), diff.SinceDateExtension)
+}
+
+// getConflictingInput returns the side whose specs conflict, paths are merged per side and the base first, so merging the base alone tells whether it is the one
+func (s *Specs) getConflictingInput(config *diff.Config) string {
+
+	if _, _, err := diff.GetPathsDiff(config, s.Base, nil); err != nil && isPathConflict(err) {
+		return InputBase
+	}
+
+	return InputRevision
+}
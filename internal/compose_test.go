@@ -0,0 +1,197 @@
+package internal_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func createServiceSpec(path string, paramType string) string {
+
+	return fmt.Sprintf(`openapi: 3.0.0
+info:
+  title: service
+  version: 1.0.0
+paths:
+  %s:
+    get:
+      parameters:
+        - name: id
+          in: query
+          schema:
+            type: %s
+      responses:
+        '200':
+          description: OK
+`, path, paramType)
+}
+
+func createComposedRequest(t *testing.T, path string, parts map[string][]string) *http.Request {
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for _, input := range []string{"base", "revision"} {
+		for i, spec := range parts[input] {
+			part, err := writer.CreateFormFile(input, fmt.Sprintf("%s-%d.yaml", input, i))
+			require.NoError(t, err)
+			_, err = part.Write([]byte(spec))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, writer.Close())
+
+	r, err := http.NewRequest(http.MethodPost, path, body)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return r
+}
+
+func TestBreakingChangesFromFile_Composed(t *testing.T) {
+
+	r := createComposedRequest(t, "/breaking-changes", map[string][]string{
+		"base":     {createServiceSpec("/users", "string"), createServiceSpec("/orders", "string")},
+		"revision": {createServiceSpec("/users", "string"), createServiceSpec("/orders", "integer")},
+	})
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "request-parameter-type-changed")
+	require.Contains(t, w.Body.String(), "revision-1.yaml")
+	require.NotContains(t, w.Body.String(), "/users")
+}
+
+func TestBreakingChangesFromFile_ComposedPathConflict(t *testing.T) {
+
+	r := createComposedRequest(t, "/breaking-changes", map[string][]string{
+		"base":     {createServiceSpec("/users", "string")},
+		"revision": {createServiceSpec("/users", "string"), createServiceSpec("/users", "integer")},
+	})
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypePathConflict, problem.Type)
+	require.Equal(t, internal.InputRevision, problem.Input)
+	require.Contains(t, problem.Detail, "revision-0.yaml")
+	require.Contains(t, problem.Detail, "revision-1.yaml")
+}
+
+func TestBreakingChangesFromFile_ComposedPathConflictBase(t *testing.T) {
+
+	r := createComposedRequest(t, "/breaking-changes", map[string][]string{
+		"base":     {createServiceSpec("/users/{id}", "string"), createServiceSpec("/users/{userId}", "string")},
+		"revision": {createServiceSpec("/users/{id}", "string")},
+	})
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypePathConflict, problem.Type)
+	require.Equal(t, internal.InputBase, problem.Input)
+	require.Contains(t, problem.Detail, "base-0.yaml")
+	require.Contains(t, problem.Detail, "base-1.yaml")
+}
+
+func TestBreakingChangesFromFile_ComposedPathConflictSameFileName(t *testing.T) {
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for input, specs := range map[string][]string{
+		"base":     {createServiceSpec("/users", "string")},
+		"revision": {createServiceSpec("/users", "string"), createServiceSpec("/users", "integer")},
+	} {
+		for _, spec := range specs {
+			part, err := writer.CreateFormFile(input, "openapi.yaml")
+			require.NoError(t, err)
+			_, err = part.Write([]byte(spec))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, writer.Close())
+	r, err := http.NewRequest(http.MethodPost, "/breaking-changes", body)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.InputRevision, problem.Input)
+	require.Contains(t, problem.Detail, "openapi.yaml and")
+	require.Contains(t, problem.Detail, "openapi.yaml#2")
+}
+
+func TestBreakingChangesFromFile_ComposedSinceDate(t *testing.T) {
+
+	newer := strings.Replace(createServiceSpec("/users", "integer"), "    get:\n", "    get:\n      x-since-date: '2024-01-01'\n", 1)
+	r := createComposedRequest(t, "/breaking-changes", map[string][]string{
+		"base":     {createServiceSpec("/users", "string")},
+		"revision": {newer, createServiceSpec("/users", "string")},
+	})
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode, w.Body.String())
+	require.Contains(t, w.Body.String(), "request-parameter-type-changed")
+}
+
+func TestDiffFromUri_ComposedUris(t *testing.T) {
+
+	r := createMockRequest(t)
+	r.URL.RawQuery = url.Values{
+		"base":     {specUri("openapi-test1.yaml")},
+		"revision": {specUri("openapi-test3.yaml")},
+		"composed": {"true"},
+	}.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromUri(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+}
+
+func TestChangelogFromFile_ArchiveGlob(t *testing.T) {
+
+	base := createZip(t, map[string]string{"users/openapi.yaml": createServiceSpec("/users", "string"), "orders/openapi.yaml": createServiceSpec("/orders", "string")})
+	revision := createZip(t, map[string]string{"users/openapi.yaml": createServiceSpec("/users", "string"), "orders/openapi.yaml": createServiceSpec("/orders", "integer")})
+	r := createMultipartRequest(t, "/changelog", base, revision)
+	r.URL.RawQuery = "entrypoint=*/openapi.yaml"
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "orders/openapi.yaml")
+}
+
+func TestDiffFromUri_InvalidComposed(t *testing.T) {
+
+	r := createMockRequest(t)
+	r.URL.RawQuery = url.Values{"base": {specUri("openapi-test1.yaml")}, "revision": {specUri("openapi-test3.yaml")}, "composed": {"maybe"}}.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, "composed", decodeProblem(t, w).Input)
+}
@@ -3,95 +3,52 @@ package internal
 import (
 	"fmt"
 	"net/http"
-	"net/url"
-	"os"
 
-	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/oasdiff/oasdiff/checker"
 	"github.com/oasdiff/oasdiff/diff"
 	"github.com/oasdiff/oasdiff/formatters"
-	"github.com/oasdiff/oasdiff/load"
-	log "github.com/sirupsen/logrus"
 )
 
 func (h *Handler) DiffFromUri(w http.ResponseWriter, r *http.Request) {
 
-	base := GetQueryString(r, "base", "")
-	if base == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	revision := GetQueryString(r, "revision", "")
-	if revision == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	baseSpec, revisionSpec, code := createSpecFromUri(base, revision)
-	if code != http.StatusOK {
-		w.WriteHeader(code)
-		return
-	}
-
-	contentType := getContentType(GetAcceptHeader(r))
-	languageCode := GetLanguageCode(GetAcceptLanguageHeader(r))
-
-	diffReport, code := createDiffReport(r, baseSpec, revisionSpec, contentType)
-	if code != http.StatusOK {
-		w.WriteHeader(code)
-		return
-	}
+	h.writeReport(w, r, reportDiff)
+}
 
-	out, err := getDiffOutput(diffReport, contentType, languageCode)
-	if err != nil {
-		log.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+func (h *Handler) DiffFromFile(w http.ResponseWriter, r *http.Request) {
 
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set(HeaderContentType, contentType)
-	_, _ = w.Write(out)
+	h.writeReport(w, r, reportDiff)
 }
 
-func (h *Handler) DiffFromFile(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) writeDiff(w http.ResponseWriter, r *http.Request, specs *Specs, options *Options) {
 
-	dir, base, revision, err := CreateFiles(r)
+	contentType, err := negotiateContentType(r, options.Format, reportMediaTypes)
 	if err != nil {
-		log.Errorf("failed to create files with %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, err)
 		return
 	}
-	defer CloseFile(base)
-	defer CloseFile(revision)
-	defer os.RemoveAll(dir)
 
-	baseSpec, revisionSpec, err := createSpecFromFile(base, revision)
+	languageCode, err := negotiateLanguage(r, options.Lang, h.getTenantSettings(r).Language)
 	if err != nil {
-		log.Errorf("failed to create spec from files with %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, err)
 		return
 	}
 
-	contentType := getContentType(GetAcceptHeader(r))
-	languageCode := GetLanguageCode(GetAcceptLanguageHeader(r))
-
-	diffReport, code := createDiffReport(r, baseSpec, revisionSpec, contentType)
-	if code != http.StatusOK {
-		w.WriteHeader(code)
+	diffReport, err := createDiffReport(specs, options, contentType)
+	if err != nil {
+		writeProblem(w, err)
 		return
 	}
 
 	out, err := getDiffOutput(diffReport, contentType, languageCode)
 	if err != nil {
-		log.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, NewProblem(http.StatusInternalServerError, ProblemTypeRenderFailed, err.Error()))
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set(HeaderContentType, contentType)
+	w.Header().Set(HeaderContentLanguage, languageCode)
+	w.Header().Set(HeaderVary, HeaderAccept+", "+HeaderAcceptLanguage)
+	w.WriteHeader(http.StatusCreated)
 	_, _ = w.Write(out)
 }
 
@@ -142,69 +99,19 @@ func getDiffOutput(diffReport *diff.Diff, contentType string, languageCode strin
 	}
 }
 
-func createSpecFromUri(base string, revision string) (*openapi3.T, *openapi3.T, int) {
-
-	loader := openapi3.NewLoader()
-	loader.IsExternalRefsAllowed = true
-
-	u, err := url.Parse(base)
-	if err != nil {
-		log.Infof("failed to url parse base spec '%s' with '%v'", base, err)
-		return nil, nil, http.StatusBadRequest
-	}
-	s1, err := loader.LoadFromURI(u)
-	if err != nil {
-		log.Infof("failed to load base spec from '%s' with '%v'", base, err)
-		return nil, nil, http.StatusBadRequest
-	}
-
-	u, err = url.Parse(revision)
-	if err != nil {
-		log.Infof("failed to url parse revision spec '%s' with '%v'", revision, err)
-		return nil, nil, http.StatusBadRequest
-	}
-	s2, err := loader.LoadFromURI(u)
-	if err != nil {
-		log.Infof("failed to load revision spec from '%s' with '%v'", revision, err)
-		return nil, nil, http.StatusBadRequest
-	}
-
-	return s1, s2, http.StatusOK
-}
-
-func createSpecFromFile(base *os.File, revision *os.File) (*openapi3.T, *openapi3.T, error) {
-
-	loader := openapi3.NewLoader()
-	loader.IsExternalRefsAllowed = false
-
-	s1, err := load.NewSpecInfo(loader, load.NewSource(base.Name()))
-	if err != nil {
-
-		return nil, nil, fmt.Errorf("failed to load base spec from '%s' with '%v'", base.Name(), err)
-	}
-
-	s2, err := load.NewSpecInfo(loader, load.NewSource(revision.Name()))
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load revision spec from '%s' with '%v'", revision.Name(), err)
-	}
-
-	return s1.Spec, s2.Spec, nil
-}
-
-func createDiffReport(r *http.Request, s1 *openapi3.T, s2 *openapi3.T, contentType string) (*diff.Diff, int) {
+func createDiffReport(specs *Specs, options *Options, contentType string) (*diff.Diff, error) {
 
-	config := CreateConfig(r)
+	config := options.getDiffConfig()
 
-	// exclude endpoints in json output
+	// endpoints are keyed by operation and path, which can't be json encoded, so json output always excludes them
 	if contentType == HeaderAppJson {
 		config.ExcludeElements.Add(diff.ExcludeEndpointsOption)
 	}
 
-	diffReport, err := diff.Get(config, s1, s2)
+	diffReport, _, err := specs.Diff(config)
 	if err != nil {
-		log.Infof("failed to calculate diff between a pair of OpenAPI objects '%s' with %v", s1.Info.Title, err)
-		return nil, http.StatusBadRequest
+		return nil, err
 	}
 
-	return diffReport, http.StatusOK
+	return diffReport, nil
 }
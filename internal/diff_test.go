@@ -2,11 +2,14 @@ package internal_test
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"regexp"
 	"testing"
 
 	"github.com/oasdiff/oasdiff-service/internal"
@@ -41,10 +44,111 @@ func TestDiffFromFile(t *testing.T) {
 	r.Header.Set("User-Agent", headerUserAgent)
 	w := httptest.NewRecorder()
 
-	internal.NewHandler().DiffFromFile(w, r)
+	createHandler(t).DiffFromFile(w, r)
 
 	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
 	diff, err := io.ReadAll(w.Result().Body)
 	require.NoError(t, err)
 	require.NotEmpty(t, diff)
 }
+
+func TestDiffFromUri_NoRevision(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", specUri("openapi-test1.yaml"))
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeMissingParameter, problem.Type)
+	require.Equal(t, internal.InputRevision, problem.Input)
+}
+
+func TestDiffFromFile_InvalidSpec(t *testing.T) {
+
+	r := createMultipartRequest(t, "/diff", readFile(t, "../data/openapi-test1.yaml"), []byte("openapi: [3.0.0"))
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeSpecLoadFailed, problem.Type)
+	require.Equal(t, internal.InputRevision, problem.Input)
+	require.NotEmpty(t, problem.Detail)
+}
+
+func TestDiffFromFile_UnsupportedMediaType(t *testing.T) {
+
+	r, err := http.NewRequest(http.MethodPost, "/diff", bytes.NewReader(readFile(t, "../data/openapi-test1.yaml")))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromFile(w, r)
+
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypeUnsupportedMediaType, decodeProblem(t, w).Type)
+}
+
+func TestDiffFromFile_PayloadTooLarge(t *testing.T) {
+
+	r := createMultipartRequest(t, "/diff", readFile(t, "../data/openapi-test1.yaml"), readFile(t, "../data/openapi-test3.yaml"))
+	w := httptest.NewRecorder()
+	r.Body = http.MaxBytesReader(w, r.Body, 100)
+
+	createHandler(t).DiffFromFile(w, r)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypePayloadTooLarge, decodeProblem(t, w).Type)
+}
+
+func TestDiffFromUri_ExcludeElements(t *testing.T) {
+
+	for query, hasEndpoints := range map[string]bool{
+		"":                           true,
+		"exclude-elements=endpoints": false,
+	} {
+		r := createBreakingChangesUriRequest(t, url.Values{})
+		r.URL.RawQuery += "&" + query
+		r.Header.Set("Accept", internal.HeaderAppYaml)
+		w := httptest.NewRecorder()
+
+		createHandler(t).DiffFromUri(w, r)
+
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		require.Equal(t, hasEndpoints, regexp.MustCompile(`(?m)^endpoints:`).MatchString(w.Body.String()), query)
+	}
+}
+
+func TestDiffFromFile_CaseInsensitiveHeaders(t *testing.T) {
+
+	const spec = `openapi: 3.0.0
+info:
+  title: headers
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      parameters:
+        - name: %s
+          in: header
+          schema:
+            type: string
+      responses:
+        '200':
+          description: OK
+`
+
+	r := createMultipartRequest(t, "/diff?case-insensitive-headers=true", []byte(fmt.Sprintf(spec, "X-User")), []byte(fmt.Sprintf(spec, "x-user")))
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Empty(t, w.Body.String())
+}
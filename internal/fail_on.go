@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/oasdiff/oasdiff/checker"
+)
+
+const (
+	// ParamFailOn is the level at or above which changes fail the request with 409 Conflict: ERR, WARN or INFO
+	ParamFailOn = "fail-on"
+
+	HeaderErrors   = "X-Oasdiff-Errors"
+	HeaderWarnings = "X-Oasdiff-Warnings"
+	HeaderInfos    = "X-Oasdiff-Infos"
+)
+
+// getFailOn returns the level of the fail-on option, or NONE if it was not given
+func getFailOn(values url.Values) (checker.Level, error) {
+
+	value := getOption(values, ParamFailOn)
+	if value == "" {
+		return checker.NONE, nil
+	}
+
+	level, err := checker.NewLevel(strings.ToLower(value))
+	if err != nil || level == checker.NONE {
+		return checker.NONE, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("invalid fail-on level '%s', use ERR, WARN or INFO", value)).WithInput(ParamFailOn)
+	}
+
+	return level, nil
+}
+
+// getChangesStatus returns 409 Conflict if some of the changes are at or above the fail-on level and 201 Created otherwise
+func getChangesStatus(changes checker.Changes, failOn checker.Level) int {
+
+	if failOn != checker.NONE && changes.HasLevelOrHigher(failOn) {
+		return http.StatusConflict
+	}
+
+	return http.StatusCreated
+}
+
+// writeLevelCountHeaders reports the number of changes of each level, so clients can gate on them without parsing the report
+func writeLevelCountHeaders(w http.ResponseWriter, counts ChangesSummary) {
+
+	w.Header().Set(HeaderErrors, strconv.Itoa(counts.Errors))
+	w.Header().Set(HeaderWarnings, strconv.Itoa(counts.Warnings))
+	w.Header().Set(HeaderInfos, strconv.Itoa(counts.Infos))
+}
@@ -0,0 +1,44 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakingChangesFromUri_FailOn(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{"fail-on": {"ERR"}}))
+
+	require.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	require.Equal(t, "2", w.Result().Header.Get(internal.HeaderErrors))
+	require.Equal(t, "4", w.Result().Header.Get(internal.HeaderWarnings))
+	require.Contains(t, w.Body.String(), "response-success-status-removed")
+}
+
+func TestBreakingChangesFromUri_FailOnNotReached(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{
+		"fail-on": {"err"},
+		"disable": {"response-success-status-removed"},
+	}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, "0", w.Result().Header.Get(internal.HeaderErrors))
+	require.Equal(t, "4", w.Result().Header.Get(internal.HeaderWarnings))
+}
+
+func TestBreakingChangesFromUri_InvalidFailOn(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).BreakingChangesFromUri(w, createBreakingChangesUriRequest(t, url.Values{"fail-on": {"NONE"}}))
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.ParamFailOn, decodeProblem(t, w).Input)
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderLastModified    = "Last-Modified"
+	HeaderIfModifiedSince = "If-Modified-Since"
+)
+
+// fetchedDocument is a document kept by the fetcher, to be reused while it is fresh and revalidated with a conditional request after that
+type fetchedDocument struct {
+	data         []byte
+	etag         string
+	lastModified string
+	validated    time.Time     // when the document was last fetched or revalidated
+	freshness    time.Duration // how long after it was validated the document is reused without revalidating it
+}
+
+func (d *fetchedDocument) size() int64 {
+
+	return int64(len(d.data) + len(d.etag) + len(d.lastModified))
+}
+
+func (d *fetchedDocument) isFresh(now time.Time) bool {
+
+	return now.Before(d.validated.Add(d.freshness))
+}
+
+// setConditions makes the request conditional, so that the server responds with 304 Not Modified if the document didn't change
+func (d *fetchedDocument) setConditions(req *http.Request) {
+
+	if d.etag != "" {
+		req.Header.Set(HeaderIfNoneMatch, d.etag)
+	}
+	if d.lastModified != "" {
+		req.Header.Set(HeaderIfModifiedSince, d.lastModified)
+	}
+}
+
+// getCached returns the cached document of the URI, or nil if there is none or it expired
+func (f *Fetcher) getCached(key string) *fetchedDocument {
+
+	if f.cache == nil {
+		return nil
+	}
+
+	res, ok := f.cache.get(key)
+	if !ok {
+		return nil
+	}
+
+	if time.Since(res.validated) > f.config.CacheTTL {
+		f.cache.remove(key)
+		return nil
+	}
+
+	return res
+}
+
+// putCached caches the document fetched, or revalidated if previous is given, unless the server forbids it or there is no way to revalidate it
+func (f *Fetcher) putCached(key string, data []byte, header http.Header, now time.Time, previous *fetchedDocument) {
+
+	if f.cache == nil {
+		return
+	}
+
+	freshness, ok := getFetchFreshness(header.Get(HeaderCacheControl), f.config.CacheFreshness)
+	if !ok {
+		f.cache.remove(key)
+		return
+	}
+
+	res := &fetchedDocument{
+		data:         data,
+		etag:         header.Get(HeaderETag),
+		lastModified: header.Get(HeaderLastModified),
+		validated:    now,
+		freshness:    freshness,
+	}
+
+	// a 304 Not Modified response may omit the validators, which are still those of the cached document
+	if previous != nil && res.etag == "" && res.lastModified == "" {
+		res.etag, res.lastModified = previous.etag, previous.lastModified
+	}
+
+	if res.etag == "" && res.lastModified == "" && res.freshness == 0 {
+		f.cache.remove(key)
+		return
+	}
+
+	f.cache.put(key, res)
+}
+
+// getFetchFreshness returns how long a document may be reused without revalidating it, up to the max freshness and to the Cache-Control max-age.
+// It returns false if Cache-Control forbids storing the document.
+func getFetchFreshness(cacheControl string, maxFreshness time.Duration) (time.Duration, bool) {
+
+	res := maxFreshness
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			res = 0
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				res = min(res, time.Duration(max(seconds, 0))*time.Second)
+			}
+		}
+	}
+
+	return res, true
+}
@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/oasdiff/go-common/env"
+)
+
+const (
+	DEFAULT_FETCH_MAX_REDIRECTS = 5
+	DEFAULT_FETCH_TIMEOUT       = 10 * time.Second
+	DEFAULT_FETCH_CACHE_SIZE    = 32 << 20
+	DEFAULT_FETCH_CACHE_TTL     = time.Hour
+)
+
+// ErrUriNotAllowed is returned when a spec or an external ref points to a location the service may not access
+var ErrUriNotAllowed = errors.New("uri not allowed")
+
+// blockedPrefixes are non-public address ranges which are not covered by the netip.Addr classification methods
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, may embed private IPv4 addresses
+	netip.MustParsePrefix("2001:db8::/32"), // documentation
+	netip.MustParsePrefix("fec0::/10"),     // deprecated site-local
+	netip.MustParsePrefix("100::/64"),      // discard-only
+	netip.MustParsePrefix("2002::/16"),     // 6to4, may embed private IPv4 addresses
+}
+
+// FetcherConfig configures how remote specs and their external refs are fetched
+type FetcherConfig struct {
+	AllowedSchemes       []string      // URI schemes which may be fetched
+	AllowedHosts         []string      // if set, only these hosts may be fetched, '*.example.com' matches subdomains
+	DeniedHosts          []string      // hosts which may never be fetched, '*.example.com' matches subdomains
+	AllowPrivateNetworks bool          // allow loopback, private and link-local addresses, for local development only
+	MaxRedirects         int           // max number of redirects followed per fetch
+	MaxSize              int64         // max size of each fetched document, in bytes
+	Timeout              time.Duration // max duration of each fetch
+	CacheSize            int64         // max size of the documents kept for conditional requests, in bytes, 0 disables the cache
+	CacheTTL             time.Duration // how long a document is kept after it was last fetched or revalidated
+	CacheFreshness       time.Duration // how long a document is reused without revalidating it, lowered by the server's Cache-Control max-age
+}
+
+// NewFetcherConfig returns the fetcher configuration, configured by environment variables
+func NewFetcherConfig() FetcherConfig {
+
+	return FetcherConfig{
+		AllowedSchemes:       []string{"http", "https"},
+		AllowedHosts:         getListFromEnv("FETCH_ALLOWED_HOSTS"),
+		DeniedHosts:          getListFromEnv("FETCH_DENIED_HOSTS"),
+		AllowPrivateNetworks: strings.EqualFold(env.GetWithDefault("FETCH_ALLOW_PRIVATE_NETWORKS", "false"), "true"),
+		MaxRedirects:         env.GetIntWithDefault("FETCH_MAX_REDIRECTS", DEFAULT_FETCH_MAX_REDIRECTS),
+		MaxSize:              int64(env.GetIntWithDefault("FETCH_MAX_SIZE", DEFAULT_MAX_SPEC_SIZE)),
+		Timeout:              time.Duration(env.GetIntWithDefault("FETCH_TIMEOUT_SECONDS", int(DEFAULT_FETCH_TIMEOUT/time.Second))) * time.Second,
+		CacheSize:            int64(env.GetIntWithDefault("FETCH_CACHE_SIZE", DEFAULT_FETCH_CACHE_SIZE)),
+		CacheTTL:             time.Duration(env.GetIntWithDefault("FETCH_CACHE_TTL_SECONDS", int(DEFAULT_FETCH_CACHE_TTL/time.Second))) * time.Second,
+		CacheFreshness:       time.Duration(env.GetIntWithDefault("FETCH_CACHE_FRESHNESS_SECONDS", 0)) * time.Second,
+	}
+}
+
+// Fetcher reads remote specs while protecting internal services from server-side request forgery
+type Fetcher struct {
+	config FetcherConfig
+	client *http.Client
+	cache  *lru[*fetchedDocument] // nil if disabled
+	record *fetchRecord           // nil unless created by withRecord
+}
+
+func NewFetcher(config FetcherConfig) *Fetcher {
+
+	f := &Fetcher{config: config}
+	if config.CacheSize > 0 {
+		f.cache = newLRU(config.CacheSize, (*fetchedDocument).size)
+	}
+
+	dialer := &net.Dialer{
+		Timeout: config.Timeout,
+		// addresses are checked after DNS resolution, right before connecting, so that DNS rebinding can't bypass the check
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return f.checkAddress(address)
+		},
+	}
+
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil, // a proxy would resolve and connect on our behalf, bypassing the address check
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   config.Timeout,
+			ResponseHeaderTimeout: config.Timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > config.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", config.MaxRedirects)
+			}
+			return f.CheckURL(req.URL)
+		},
+		Timeout: config.Timeout,
+	}
+
+	return f
+}
+
+// NewLoader returns a spec loader which fetches the spec and all of its external refs through the fetcher
+func (f *Fetcher) NewLoader() *openapi3.Loader {
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = f.ReadFromURI
+
+	return loader
+}
+
+// withRecord returns a fetcher sharing the client and cache of f, which records the documents it reads
+func (f *Fetcher) withRecord() *Fetcher {
+
+	res := *f
+	res.record = &fetchRecord{hashes: map[string]string{}}
+
+	return &res
+}
+
+// getFetched returns the hashes of the documents read by a fetcher created by withRecord, keyed by their URI
+func (f *Fetcher) getFetched() map[string]string {
+
+	return f.record.get()
+}
+
+// fetchRecord records the documents read while loading the specs of a request, so that a report of specs given as URIs is cached by the content it was rendered from
+type fetchRecord struct {
+	mutex  sync.Mutex
+	hashes map[string]string
+}
+
+func (r *fetchRecord) add(uri string, data []byte) {
+
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.hashes[uri] = getHash(data)
+}
+
+func (r *fetchRecord) get() map[string]string {
+
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return maps.Clone(r.hashes)
+}
+
+// ReadFromURI implements openapi3.ReadFromURIFunc, both specs given as URIs and their external refs are read with it.
+// Documents with an ETag or Last-Modified header are cached, and revalidated with a conditional request once they are no longer fresh.
+func (f *Fetcher) ReadFromURI(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+
+	res, err := f.fetch(loader, location)
+	if err != nil {
+		return nil, err
+	}
+	f.record.add(location.String(), res)
+
+	return res, nil
+}
+
+func (f *Fetcher) fetch(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+
+	if err := f.CheckURL(location); err != nil {
+		return nil, err
+	}
+
+	key := location.String()
+	cached := f.getCached(key)
+	if cached != nil && cached.isFresh(time.Now()) {
+		return cached.data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(loader.Context, f.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		cached.setConditions(req)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		f.putCached(key, cached.data, resp.Header, time.Now(), cached)
+		return cached.data, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch '%s' with status %d", location.Redacted(), resp.StatusCode)
+	}
+
+	// read one extra byte to detect documents exceeding the limit
+	res, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s' with %w", location.Redacted(), err)
+	}
+	if int64(len(res)) > f.config.MaxSize {
+		return nil, fmt.Errorf("'%s' exceeds %d bytes", location.Redacted(), f.config.MaxSize)
+	}
+
+	f.putCached(key, res, resp.Header, time.Now(), nil)
+
+	return res, nil
+}
+
+// CheckURL returns ErrUriNotAllowed if the scheme or host of the URL may not be fetched
+func (f *Fetcher) CheckURL(location *url.URL) error {
+
+	if !slices.Contains(f.config.AllowedSchemes, strings.ToLower(location.Scheme)) {
+		return fmt.Errorf("%w: scheme '%s' is not allowed in '%s', use one of %s", ErrUriNotAllowed, location.Scheme, location.Redacted(), strings.Join(f.config.AllowedSchemes, ", "))
+	}
+
+	host := strings.ToLower(location.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: no host in '%s'", ErrUriNotAllowed, location.Redacted())
+	}
+
+	if matchHost(f.config.DeniedHosts, host) {
+		return fmt.Errorf("%w: host '%s' is denied", ErrUriNotAllowed, host)
+	}
+
+	if len(f.config.AllowedHosts) > 0 && !matchHost(f.config.AllowedHosts, host) {
+		return fmt.Errorf("%w: host '%s' is not allowed", ErrUriNotAllowed, host)
+	}
+
+	// literal IPs are checked here too for a clear error, hostnames are checked once resolved
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return f.checkIP(ip)
+	}
+
+	return nil
+}
+
+func (f *Fetcher) checkAddress(address string) error {
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse address '%s' with %v", ErrUriNotAllowed, address, err)
+	}
+
+	return f.checkIP(addrPort.Addr())
+}
+
+func (f *Fetcher) checkIP(ip netip.Addr) error {
+
+	if f.config.AllowPrivateNetworks {
+		return nil
+	}
+
+	if isBlockedIP(ip) || ip.Is4In6() && isBlockedIP(ip.Unmap()) {
+		return fmt.Errorf("%w: address '%s' is not public", ErrUriNotAllowed, ip)
+	}
+
+	return nil
+}
+
+// isBlockedIP reports whether the address is not public, link-local covers cloud metadata services such as 169.254.169.254
+func isBlockedIP(ip netip.Addr) bool {
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchHost reports whether the host matches one of the patterns, '*.example.com' matches subdomains of example.com
+func matchHost(patterns []string, host string) bool {
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getListFromEnv(key string) []string {
+
+	var res []string
+	for _, item := range strings.Split(env.GetWithDefault(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+
+	return res
+}
+
+// checkSpecUri makes sure that a spec URI given by the user may be fetched
+func checkSpecUri(fetcher *Fetcher, uri string, input string) error {
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("failed to url parse spec '%s' with '%v'", uri, err)).WithInput(input)
+	}
+
+	if err := fetcher.CheckURL(u); err != nil {
+		return NewProblem(http.StatusBadRequest, ProblemTypeUriNotAllowed, err.Error()).WithInput(input)
+	}
+
+	return nil
+}
@@ -0,0 +1,336 @@
+package internal_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+const specWithFileRef = `openapi: 3.0.0
+info:
+  title: test
+  version: v1
+paths:
+  /users:
+    get:
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: 'file:///etc/passwd#/components/schemas/User'
+`
+
+func TestFetcher_CheckURL(t *testing.T) {
+
+	config := internal.NewFetcherConfig()
+	config.DeniedHosts = []string{"*.internal.example.com"}
+	f := internal.NewFetcher(config)
+
+	for _, uri := range []string{
+		"../data/openapi-test1.yaml",
+		"/etc/passwd",
+		"file:///etc/passwd",
+		"ftp://example.com/openapi.yaml",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://127.0.0.1:8080/openapi.yaml",
+		"http://[::1]/openapi.yaml",
+		"http://[::ffff:127.0.0.1]/openapi.yaml",
+		"http://10.0.0.1/openapi.yaml",
+		"http://100.64.0.1/openapi.yaml",
+		"http://api.internal.example.com/openapi.yaml",
+	} {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		require.ErrorIs(t, f.CheckURL(u), internal.ErrUriNotAllowed, uri)
+	}
+
+	u, err := url.Parse("https://raw.githubusercontent.com/oasdiff/oasdiff/main/data/openapi-test1.yaml")
+	require.NoError(t, err)
+	require.NoError(t, f.CheckURL(u))
+}
+
+func TestFetcher_AllowedHosts(t *testing.T) {
+
+	config := internal.NewFetcherConfig()
+	config.AllowedHosts = []string{"specs.example.com", "*.artifacts.example.com"}
+	f := internal.NewFetcher(config)
+
+	for uri, allowed := range map[string]bool{
+		"https://specs.example.com/openapi.yaml":         true,
+		"https://eu.artifacts.example.com/openapi.yaml":  true,
+		"https://artifacts.example.com/openapi.yaml":     false,
+		"https://specs.example.com.evil.io/openapi.yaml": false,
+		"https://raw.githubusercontent.com/openapi.yaml": false,
+	} {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		if allowed {
+			require.NoError(t, f.CheckURL(u), uri)
+		} else {
+			require.ErrorIs(t, f.CheckURL(u), internal.ErrUriNotAllowed, uri)
+		}
+	}
+}
+
+func TestFetcher_BlocksResolvedPrivateAddress(t *testing.T) {
+
+	// 'localhost' passes the URL check and is only blocked once resolved to a loopback address
+	u, err := url.Parse(specUri("openapi-test1.yaml"))
+	require.NoError(t, err)
+	u.Host = "localhost:" + u.Port()
+
+	_, err = internal.NewFetcher(internal.NewFetcherConfig()).ReadFromURI(openapi3.NewLoader(), u)
+	require.ErrorIs(t, err, internal.ErrUriNotAllowed)
+}
+
+func TestFetcher_MaxSize(t *testing.T) {
+
+	config := internal.NewFetcherConfig()
+	config.AllowPrivateNetworks = true
+	config.MaxSize = 100
+
+	u, err := url.Parse(specUri("openapi-test1.yaml"))
+	require.NoError(t, err)
+
+	_, err = internal.NewFetcher(config).ReadFromURI(openapi3.NewLoader(), u)
+	require.ErrorContains(t, err, "exceeds 100 bytes")
+}
+
+func TestFetcher_MaxRedirects(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+	}))
+	defer server.Close()
+
+	config := internal.NewFetcherConfig()
+	config.AllowPrivateNetworks = true
+	config.MaxRedirects = 2
+
+	u, err := url.Parse(server.URL + "/openapi.yaml")
+	require.NoError(t, err)
+
+	_, err = internal.NewFetcher(config).ReadFromURI(openapi3.NewLoader(), u)
+	require.ErrorContains(t, err, "stopped after 2 redirects")
+}
+
+func TestDiffFromUri_ExternalRefNotAllowed(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(specWithFileRef))
+	}))
+	defer server.Close()
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", server.URL+"/openapi.yaml")
+	q.Add("revision", specUri("openapi-test3.yaml"))
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.InputBase, problem.Input)
+	require.Contains(t, problem.Detail, "scheme 'file' is not allowed")
+}
+
+func TestChangelogFromUri_LocalFileNotAllowed(t *testing.T) {
+
+	r := createMockRequest(t)
+	q := r.URL.Query()
+	q.Add("base", "/etc/passwd")
+	q.Add("revision", specUri("openapi-test3.yaml"))
+	r.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeUriNotAllowed, problem.Type)
+	require.Equal(t, internal.InputBase, problem.Input)
+}
+
+const specWithRef = `openapi: 3.0.0
+info:
+  title: test
+  version: v1
+paths:
+  /users:
+    get:
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: 'schemas.yaml#/User'
+`
+
+const specSchemas = `User:
+  type: object
+`
+
+// versionedServer serves documents with an ETag and records whether each request was conditional
+type versionedServer struct {
+	*httptest.Server
+	mutex       sync.Mutex
+	docs        map[string]string
+	cacheHeader string
+	requests    []string // path of each request, with '?' if it was conditional
+}
+
+func newVersionedServer(docs map[string]string, cacheHeader string) *versionedServer {
+
+	res := &versionedServer{docs: docs, cacheHeader: cacheHeader}
+	res.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		res.mutex.Lock()
+		defer res.mutex.Unlock()
+
+		doc, ok := res.docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(doc)))
+		w.Header().Set("ETag", etag)
+		if res.cacheHeader != "" {
+			w.Header().Set("Cache-Control", res.cacheHeader)
+		}
+
+		if r.Header.Get("If-None-Match") != "" {
+			res.requests = append(res.requests, r.URL.Path+"?")
+		} else {
+			res.requests = append(res.requests, r.URL.Path)
+		}
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	}))
+
+	return res
+}
+
+func (s *versionedServer) getRequests() []string {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+func (s *versionedServer) setDoc(path string, doc string) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.docs[path] = doc
+}
+
+func readFromServer(t *testing.T, f *internal.Fetcher, uri string) string {
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+
+	res, err := f.ReadFromURI(openapi3.NewLoader(), u)
+	require.NoError(t, err)
+
+	return string(res)
+}
+
+func TestFetcher_ConditionalRequest(t *testing.T) {
+
+	server := newVersionedServer(map[string]string{"/openapi.yaml": "v1"}, "")
+	defer server.Close()
+	f := createFetcher()
+
+	require.Equal(t, "v1", readFromServer(t, f, server.URL+"/openapi.yaml"))
+	require.Equal(t, "v1", readFromServer(t, f, server.URL+"/openapi.yaml"))
+
+	server.setDoc("/openapi.yaml", "v2")
+	require.Equal(t, "v2", readFromServer(t, f, server.URL+"/openapi.yaml"))
+
+	require.Equal(t, []string{"/openapi.yaml", "/openapi.yaml?", "/openapi.yaml?"}, server.getRequests())
+}
+
+func TestFetcher_CacheFreshness(t *testing.T) {
+
+	server := newVersionedServer(map[string]string{"/openapi.yaml": "v1"}, "max-age=60")
+	defer server.Close()
+
+	config := internal.NewFetcherConfig()
+	config.AllowPrivateNetworks = true
+	config.CacheFreshness = time.Hour
+	f := internal.NewFetcher(config)
+
+	require.Equal(t, "v1", readFromServer(t, f, server.URL+"/openapi.yaml"))
+	server.setDoc("/openapi.yaml", "v2")
+	require.Equal(t, "v1", readFromServer(t, f, server.URL+"/openapi.yaml"), "fresh documents are reused without a request")
+
+	require.Equal(t, []string{"/openapi.yaml"}, server.getRequests())
+}
+
+func TestFetcher_CacheNoStore(t *testing.T) {
+
+	server := newVersionedServer(map[string]string{"/openapi.yaml": "v1"}, "no-store")
+	defer server.Close()
+	f := createFetcher()
+
+	readFromServer(t, f, server.URL+"/openapi.yaml")
+	readFromServer(t, f, server.URL+"/openapi.yaml")
+
+	require.Equal(t, []string{"/openapi.yaml", "/openapi.yaml"}, server.getRequests())
+}
+
+func TestFetcher_CacheDisabled(t *testing.T) {
+
+	server := newVersionedServer(map[string]string{"/openapi.yaml": "v1"}, "")
+	defer server.Close()
+
+	config := internal.NewFetcherConfig()
+	config.AllowPrivateNetworks = true
+	config.CacheSize = 0
+	f := internal.NewFetcher(config)
+
+	readFromServer(t, f, server.URL+"/openapi.yaml")
+	readFromServer(t, f, server.URL+"/openapi.yaml")
+
+	require.Equal(t, []string{"/openapi.yaml", "/openapi.yaml"}, server.getRequests())
+}
+
+func TestDiffFromUri_ConditionalExternalRefs(t *testing.T) {
+
+	server := newVersionedServer(map[string]string{"/openapi.yaml": specWithRef, "/schemas.yaml": specSchemas}, "")
+	defer server.Close()
+	h := createHandler(t)
+
+	for range 2 {
+		r := createMockRequest(t)
+		r.URL.RawQuery = url.Values{"base": {server.URL + "/openapi.yaml"}, "revision": {specUri("openapi-test3.yaml")}}.Encode()
+		w := httptest.NewRecorder()
+		h.DiffFromUri(w, r)
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode, w.Body.String())
+	}
+
+	// the second diff revalidates both the spec and its external ref
+	require.Equal(t, []string{"/openapi.yaml", "/schemas.yaml", "/openapi.yaml?", "/schemas.yaml?"}, server.getRequests())
+}
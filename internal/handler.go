@@ -1,7 +1,24 @@
 package internal
 
-type Handler struct{}
+import (
+	"github.com/oasdiff/go-common/ds"
+	"github.com/oasdiff/oasdiff-service/internal/badge"
+)
 
-func NewHandler() *Handler {
-	return &Handler{}
+type Handler struct {
+	dsc            ds.Client
+	badgeGenerator *badge.Generator
+	limits         Limits
+	fetcher        *Fetcher
+	cache          *Cache
+}
+
+func NewHandler(dsc ds.Client, badgeGenerator *badge.Generator, limits Limits, fetcher *Fetcher, cache *Cache) *Handler {
+	return &Handler{
+		dsc:            dsc,
+		badgeGenerator: badgeGenerator,
+		limits:         limits,
+		fetcher:        fetcher,
+		cache:          cache,
+	}
 }
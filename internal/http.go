@@ -2,20 +2,26 @@ package internal
 
 import (
 	"net/http"
-	"strings"
 )
 
 const (
-	HeaderContentType       = "Content-Type"
-	HeaderAccept            = "Accept"
-	HeaderAcceptLanguage    = "Accept-Language"
-	HeaderAppYaml           = "application/yaml"
-	HeaderAppJson           = "application/json"
-	HeaderTextHtml          = "text/html"
-	HeaderTextPlain         = "text/plain"
-	HeaderTextMarkdown      = "text/markdown"
-	HeaderMultipartFormData = "multipart/form-data"
-	HeaderAppFormUrlEncoded = "application/x-www-form-urlencoded"
+	HeaderContentType        = "Content-Type"
+	HeaderAccept             = "Accept"
+	HeaderAcceptLanguage     = "Accept-Language"
+	HeaderContentLanguage    = "Content-Language"
+	HeaderContentDisposition = "Content-Disposition"
+	HeaderContentTypeOptions = "X-Content-Type-Options"
+	HeaderAppYaml            = "application/yaml"
+	HeaderAppJson            = "application/json"
+	HeaderAppXYaml           = "application/x-yaml"
+	HeaderTextYaml           = "text/yaml"
+	HeaderAppOpenApi         = "application/vnd.oai.openapi"
+	HeaderAppOpenApiJson     = "application/vnd.oai.openapi+json"
+	HeaderTextHtml           = "text/html"
+	HeaderTextPlain          = "text/plain"
+	HeaderTextMarkdown       = "text/markdown"
+	HeaderMultipartFormData  = "multipart/form-data"
+	HeaderAppFormUrlEncoded  = "application/x-www-form-urlencoded"
 )
 
 func GetAcceptHeader(r *http.Request) string {
@@ -28,31 +34,6 @@ func GetAcceptLanguageHeader(r *http.Request) string {
 	return r.Header.Get(HeaderAcceptLanguage)
 }
 
-func GetLanguageCode(acceptLanguageHeader string) string {
-	if acceptLanguageHeader == "" {
-		return "en"
-	}
-
-	languages := strings.Split(acceptLanguageHeader, ",")
-	for _, lang := range languages {
-		lang = strings.TrimSpace(lang)
-		lang = strings.Split(lang, ";")[0]
-
-		switch strings.ToLower(lang) {
-		case "en", "en-us", "en-gb":
-			return "en"
-		case "ru", "ru-ru":
-			return "ru"
-		case "pt-br", "pt":
-			return "pt-br"
-		case "es", "es-es", "es-mx":
-			return "es"
-		}
-	}
-
-	return "en"
-}
-
 func GetQueryString(r *http.Request, key string, defaultValue string) string {
 
 	if val, ok := r.URL.Query()[key]; ok {
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/oasdiff/oasdiff/checker"
+)
+
+const (
+	// InputIgnore is the ignore file of changes of all levels, given as a URI query parameter or in the request body
+	InputIgnore = "ignore"
+	// InputErrIgnore is the ignore file of ERR changes, like the oasdiff CLI's err-ignore file
+	InputErrIgnore = "err-ignore"
+	// InputWarnIgnore is the ignore file of WARN changes, like the oasdiff CLI's warn-ignore file
+	InputWarnIgnore = "warn-ignore"
+
+	HeaderIgnoreMatched     = "X-Oasdiff-Ignore-Matched"
+	HeaderIgnoreUnused      = "X-Oasdiff-Ignore-Unused"
+	HeaderErrIgnoreMatched  = "X-Oasdiff-Err-Ignore-Matched"
+	HeaderErrIgnoreUnused   = "X-Oasdiff-Err-Ignore-Unused"
+	HeaderWarnIgnoreMatched = "X-Oasdiff-Warn-Ignore-Matched"
+	HeaderWarnIgnoreUnused  = "X-Oasdiff-Warn-Ignore-Unused"
+)
+
+// ignoreInput is an input of an ignore file, with the level of the changes it applies to and the headers reporting its lines
+type ignoreInput struct {
+	name    string
+	level   checker.Level // NONE applies to changes of all levels
+	matched string
+	unused  string
+}
+
+// ignoreInputs are the ignore files of a request, in the order they are applied
+var ignoreInputs = []ignoreInput{
+	{name: InputIgnore, level: checker.NONE, matched: HeaderIgnoreMatched, unused: HeaderIgnoreUnused},
+	{name: InputErrIgnore, level: checker.ERR, matched: HeaderErrIgnoreMatched, unused: HeaderErrIgnoreUnused},
+	{name: InputWarnIgnore, level: checker.WARN, matched: HeaderWarnIgnoreMatched, unused: HeaderWarnIgnoreUnused},
+}
+
+func isIgnoreInput(name string) bool {
+
+	for _, input := range ignoreInputs {
+		if input.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IgnoreFile lists accepted changes, one per line, in the format of the oasdiff CLI's err-ignore and warn-ignore files.
+// A line ignores the changes of the file's level whose operation, path and text it contains, a file of level NONE matches changes of all levels.
+type IgnoreFile struct {
+	lines []string
+	level checker.Level
+}
+
+func NewIgnoreFile(data []byte, level checker.Level) *IgnoreFile {
+
+	return &IgnoreFile{lines: strings.Split(string(data), "\n"), level: level}
+}
+
+// Filter returns the changes which are not ignored, with the line numbers of the lines which matched a change and of those which did not.
+// Blank lines are neither matched nor unused.
+func (f *IgnoreFile) Filter(changes checker.Changes, l checker.Localizer) (checker.Changes, []int, []int) {
+
+	ignored := make([]bool, len(changes))
+	var matched, unused []int
+
+	for i, line := range f.lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		line = strings.ToLower(line)
+		path := getIgnoreLinePath(line)
+		used := false
+		for j, change := range changes {
+			if f.level != checker.NONE && change.GetLevel() != f.level {
+				continue
+			}
+			if change.MatchIgnore(path, line, l) {
+				ignored[j] = true
+				used = true
+			}
+		}
+
+		if used {
+			matched = append(matched, i+1)
+		} else {
+			unused = append(unused, i+1)
+		}
+	}
+
+	res := checker.Changes{}
+	for i, change := range changes {
+		if !ignored[i] {
+			res = append(res, change)
+		}
+	}
+
+	return res, matched, unused
+}
+
+// getIgnoreLinePath returns the path of an ignore line, the first field starting with a slash, like the oasdiff CLI does
+func getIgnoreLinePath(line string) string {
+
+	for _, field := range strings.Fields(line) {
+		if strings.HasPrefix(field, "/") {
+			return field
+		}
+	}
+
+	return ""
+}
+
+// writeIgnoreHeaders reports the numbers of the lines of the input's ignore file which matched a change and of those which did not
+func writeIgnoreHeaders(w http.ResponseWriter, input ignoreInput, matched []int, unused []int) {
+
+	w.Header().Set(input.matched, joinInts(matched))
+	w.Header().Set(input.unused, joinInts(unused))
+}
+
+func joinInts(values []int) string {
+
+	res := make([]string, len(values))
+	for i, value := range values {
+		res[i] = strconv.Itoa(value)
+	}
+
+	return strings.Join(res, ",")
+}
+
+// ignoreSource is an ignore file given either as a URI or in the request body
+type ignoreSource struct {
+	uri  string
+	data []byte
+}
+
+// readIgnoreFiles returns the ignore files of the options keyed by their input.
+// Each is given either as a URI, fetched with the fetcher, or in the request body as a multipart part, a form field or a JSON request field.
+func readIgnoreFiles(options *Options, limits Limits, fetcher *Fetcher) (map[string]*IgnoreFile, error) {
+
+	res := map[string]*IgnoreFile{}
+	for _, input := range ignoreInputs {
+		source, ok := options.ignores[input.name]
+		if !ok {
+			continue
+		}
+
+		data := source.data
+		if source.uri != "" {
+			var err error
+			if data, err = fetchIgnoreFile(source.uri, input.name, limits, fetcher); err != nil {
+				return nil, err
+			}
+		}
+		res[input.name] = NewIgnoreFile(data, input.level)
+	}
+
+	return res, nil
+}
+
+func fetchIgnoreFile(uri string, input string, limits Limits, fetcher *Fetcher) ([]byte, error) {
+
+	if err := checkSpecUri(fetcher, uri, input); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("failed to url parse ignore file '%s' with '%v'", uri, err)).WithInput(input)
+	}
+
+	data, err := fetcher.ReadFromURI(openapi3.NewLoader(), u)
+	if err != nil {
+		if errors.Is(err, ErrUriNotAllowed) {
+			return nil, NewProblem(http.StatusBadRequest, ProblemTypeUriNotAllowed, err.Error()).WithInput(input)
+		}
+		return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("failed to fetch ignore file with %v", err)).WithInput(input)
+	}
+	if int64(len(data)) > limits.MaxSpecSize {
+		return nil, newIgnoreFileTooLargeProblem(input, limits)
+	}
+
+	return data, nil
+}
+
+func newIgnoreFileTooLargeProblem(input string, limits Limits) *Problem {
+	return NewProblem(http.StatusRequestEntityTooLarge, ProblemTypePayloadTooLarge, fmt.Sprintf("ignore file exceeds %d bytes", limits.MaxSpecSize)).WithInput(input)
+}
@@ -0,0 +1,140 @@
+package internal_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+const ignoreFile = `GET /api/{domain}/{project}/badges/security-score removed the success response with the status '200'
+
+GET /api/{domain}/{project}/install-command deleted the 'header' request parameter 'network-policies'
+GET /api/no-such-path deleted the 'query' request parameter 'filter'
+`
+
+func TestBreakingChangesFromFile_Ignore(t *testing.T) {
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, data := range map[string][]byte{
+		"base":     readFile(t, "../data/openapi-test1.yaml"),
+		"revision": readFile(t, "../data/openapi-test3.yaml"),
+		"ignore":   []byte(ignoreFile),
+	} {
+		part, err := writer.CreateFormFile(name, name)
+		require.NoError(t, err)
+		_, err = part.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	r, err := http.NewRequest(http.MethodPost, "/breaking-changes", body)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, "1,3", w.Result().Header.Get(internal.HeaderIgnoreMatched))
+	require.Equal(t, "4", w.Result().Header.Get(internal.HeaderIgnoreUnused))
+	require.NotContains(t, w.Body.String(), "network-policies")
+	require.Contains(t, w.Body.String(), "response-success-status-removed")
+}
+
+func TestChangelogFromUri_IgnoreUri(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(ignoreFile))
+	}))
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	createHandler(t).ChangelogFromUri(w, createBreakingChangesUriRequest(t, url.Values{"ignore": {server.URL}}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, "1,3", w.Result().Header.Get(internal.HeaderIgnoreMatched))
+	require.NotContains(t, w.Body.String(), "request parameter 'network-policies'")
+}
+
+func TestChangelogFromFile_IgnoreUriAndBody(t *testing.T) {
+
+	r := createJsonRequest(t, "/changelog", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision: internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+		Ignore:   ignoreFile,
+	})
+	r.URL.RawQuery = url.Values{"ignore": {specUri("ignore.txt")}}.Encode()
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.InputIgnore, decodeProblem(t, w).Input)
+}
+
+func TestBreakingChangesFromFile_IgnoreFormField(t *testing.T) {
+
+	form := url.Values{
+		"base":     {string(readFile(t, "../data/openapi-test1.yaml"))},
+		"revision": {string(readFile(t, "../data/openapi-test3.yaml"))},
+		"ignore":   {ignoreFile},
+	}
+	r, err := http.NewRequest(http.MethodPost, "/breaking-changes", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", internal.HeaderAppFormUrlEncoded)
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, "1,3", w.Result().Header.Get(internal.HeaderIgnoreMatched))
+	require.NotContains(t, w.Body.String(), "network-policies")
+}
+
+func TestBreakingChangesFromFile_LevelIgnore(t *testing.T) {
+
+	r := createJsonRequest(t, "/breaking-changes", internal.JsonRequest{
+		Base:       internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision:   internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+		ErrIgnore:  ignoreFile,
+		WarnIgnore: ignoreFile,
+	})
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).BreakingChangesFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, "1", w.Result().Header.Get(internal.HeaderErrIgnoreMatched))
+	require.Equal(t, "3,4", w.Result().Header.Get(internal.HeaderErrIgnoreUnused))
+	require.Equal(t, "3", w.Result().Header.Get(internal.HeaderWarnIgnoreMatched))
+	require.Equal(t, "1,4", w.Result().Header.Get(internal.HeaderWarnIgnoreUnused))
+	require.Empty(t, w.Result().Header.Get(internal.HeaderIgnoreMatched))
+	require.NotContains(t, w.Body.String(), "network-policies")
+	require.NotContains(t, w.Body.String(), "status '200'")
+}
+
+func TestChangelogFromUri_ErrIgnoreSkipsWarnings(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(ignoreFile))
+	}))
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	createHandler(t).ChangelogFromUri(w, createBreakingChangesUriRequest(t, url.Values{"err-ignore": {server.URL}}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, "1", w.Result().Header.Get(internal.HeaderErrIgnoreMatched))
+	require.Contains(t, w.Body.String(), "network-policies")
+}
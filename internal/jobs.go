@@ -0,0 +1,294 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/env"
+	"github.com/oasdiff/go-common/tenant"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_JOB_WORKERS     = 4
+	DEFAULT_JOB_QUEUE_SIZE  = 100
+	DEFAULT_JOB_TTL_SECONDS = 3600
+
+	// ParamJobType is the kind of report a job creates: diff, changelog or breaking-changes
+	ParamJobType = "type"
+	// PathParamJobId is the id of a job in the job path
+	PathParamJobId = "job-id"
+
+	HeaderJobStatus  = "X-Oasdiff-Job-Status"
+	HeaderLocation   = "Location"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// job statuses, a job's result is available once it is done
+const (
+	JobStatusQueued  = "queued"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+)
+
+// jobTypes are the reports a job can create, like the synchronous endpoint of the same name
+var jobTypes = map[string]report{
+	reportDiff.name:            reportDiff,
+	reportChangelog.name:       reportChangelog,
+	reportBreakingChanges.name: reportBreakingChanges,
+}
+
+// JobsConfig bounds the jobs which run in the background
+type JobsConfig struct {
+	Workers   int           // number of jobs running at the same time
+	QueueSize int           // max number of jobs waiting for a worker, more are rejected with 503
+	TTL       time.Duration // how long the result of a job is kept after it is done
+}
+
+// NewJobsConfig returns the deployment's jobs config, configured by environment variables
+func NewJobsConfig() JobsConfig {
+
+	return JobsConfig{
+		Workers:   env.GetIntWithDefault("JOB_WORKERS", DEFAULT_JOB_WORKERS),
+		QueueSize: env.GetIntWithDefault("JOB_QUEUE_SIZE", DEFAULT_JOB_QUEUE_SIZE),
+		TTL:       time.Duration(env.GetIntWithDefault("JOB_TTL_SECONDS", DEFAULT_JOB_TTL_SECONDS)) * time.Second,
+	}
+}
+
+// Jobs runs diff, changelog and breaking-changes requests in a bounded in-process worker pool, for specs which take longer than the server's write timeout.
+// Jobs are kept in memory, so they are lost on restart and are only found on the instance which created them.
+type Jobs struct {
+	handler *Handler
+	config  JobsConfig
+	queue   chan *job
+
+	mutex sync.Mutex
+	jobs  map[string]*job
+}
+
+type job struct {
+	id       string
+	tenantId string
+	jobType  string
+	status   string
+	created  time.Time
+	finished time.Time
+
+	// the request, its specs and options until the job runs, then its response
+	request  *http.Request
+	sources  SpecSources
+	options  *Options
+	limits   Limits
+	response *recordedResponse
+}
+
+// JobStatus reports a job which is not done yet
+type JobStatus struct {
+	Id      string    `json:"id"`
+	Type    string    `json:"type"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+}
+
+// NewJobs starts the workers and the expiry of results
+func NewJobs(h *Handler, config JobsConfig) *Jobs {
+
+	res := &Jobs{
+		handler: h,
+		config:  config,
+		queue:   make(chan *job, config.QueueSize),
+		jobs:    map[string]*job{},
+	}
+
+	for range max(config.Workers, 1) {
+		go res.work()
+	}
+	go res.expire()
+
+	return res
+}
+
+// Create reads the specs like the synchronous endpoints and queues a job which loads them and creates the report in the background.
+// The response is 202 Accepted with the job's status and its location.
+func (j *Jobs) Create(w http.ResponseWriter, r *http.Request) {
+
+	jobType := GetQueryString(r, ParamJobType, "")
+	if _, ok := jobTypes[jobType]; !ok {
+		types := make([]string, 0, len(jobTypes))
+		for curr := range jobTypes {
+			types = append(types, curr)
+		}
+		slices.Sort(types)
+		writeProblem(w, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter,
+			fmt.Sprintf("invalid job type '%s', use %s", jobType, strings.Join(types, ", "))).WithInput(ParamJobType))
+		return
+	}
+
+	r = j.handler.withTenantSettings(r)
+	limits := j.handler.getLimits(r)
+	sources, options, err := ReadSpecSources(w, r, limits, j.handler.fetcher)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	id, err := newJobId()
+	if err != nil {
+		writeProblem(w, newServerProblem(fmt.Sprintf("failed to create job id with %v", err)))
+		return
+	}
+
+	// the job outlives the request, it keeps the request's values such as the tenant but not its cancellation
+	newJob := &job{
+		id:       id,
+		tenantId: mux.Vars(r)[tenant.PathParamTenantId],
+		jobType:  jobType,
+		status:   JobStatusQueued,
+		created:  time.Now(),
+		request:  r.WithContext(context.WithoutCancel(r.Context())),
+		sources:  sources,
+		options:  options,
+		limits:   limits,
+	}
+
+	j.mutex.Lock()
+	j.jobs[id] = newJob
+	status := newJob.getStatus()
+	j.mutex.Unlock()
+
+	select {
+	case j.queue <- newJob:
+	default:
+		j.mutex.Lock()
+		delete(j.jobs, id)
+		j.mutex.Unlock()
+		w.Header().Set(HeaderRetryAfter, "60")
+		writeProblem(w, NewProblem(http.StatusServiceUnavailable, ProblemTypeJobQueueFull, fmt.Sprintf("%d jobs are already queued, retry later", j.config.QueueSize)))
+		return
+	}
+
+	writeJobStatus(w, http.StatusAccepted, status, r.URL.Path+"/"+id)
+}
+
+// Get reports the status of a job while it is queued or running, with 202 Accepted.
+// Once it is done, the response is the job's report, exactly as the synchronous endpoint would have responded.
+func (j *Jobs) Get(w http.ResponseWriter, r *http.Request) {
+
+	id := mux.Vars(r)[PathParamJobId]
+
+	j.mutex.Lock()
+	curr, ok := j.jobs[id]
+	if ok && (curr.tenantId != mux.Vars(r)[tenant.PathParamTenantId] || j.isExpired(curr, time.Now())) {
+		ok = false
+	}
+	var status JobStatus
+	var response *recordedResponse
+	if ok {
+		status, response = curr.getStatus(), curr.response
+	}
+	j.mutex.Unlock()
+
+	if !ok {
+		writeProblem(w, NewProblem(http.StatusNotFound, ProblemTypeJobNotFound, fmt.Sprintf("job '%s' not found, results expire %v after the job is done", id, j.config.TTL)).WithInput(PathParamJobId))
+		return
+	}
+
+	if response == nil {
+		w.Header().Set(HeaderRetryAfter, "1")
+		writeJobStatus(w, http.StatusAccepted, status, "")
+		return
+	}
+
+	w.Header().Set(HeaderJobStatus, JobStatusDone)
+	response.writeTo(w, r)
+}
+
+func writeJobStatus(w http.ResponseWriter, status int, jobStatus JobStatus, location string) {
+
+	out, err := json.Marshal(jobStatus)
+	if err != nil {
+		writeProblem(w, newServerProblem(fmt.Sprintf("failed to json encode job status with %v", err)))
+		return
+	}
+
+	if location != "" {
+		w.Header().Set(HeaderLocation, location)
+	}
+	w.Header().Set(HeaderJobStatus, jobStatus.Status)
+	w.Header().Set(HeaderContentType, HeaderAppJson)
+	w.WriteHeader(status)
+	_, _ = w.Write(out)
+}
+
+func (j *job) getStatus() JobStatus {
+
+	return JobStatus{Id: j.id, Type: j.jobType, Status: j.status, Created: j.created}
+}
+
+func (j *Jobs) work() {
+
+	for curr := range j.queue {
+		j.mutex.Lock()
+		curr.status = JobStatusRunning
+		j.mutex.Unlock()
+
+		response := j.run(curr)
+
+		j.mutex.Lock()
+		curr.status, curr.finished, curr.response = JobStatusDone, time.Now(), response
+		curr.request, curr.sources, curr.options = nil, nil, nil
+		j.mutex.Unlock()
+	}
+}
+
+// run loads the job's specs and renders its report, a panic is reported as an internal problem so it doesn't stop the worker
+func (j *Jobs) run(curr *job) (res *recordedResponse) {
+
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorf("job '%s' failed with %v", curr.id, err)
+			res = newRecordedResponse()
+			writeProblem(res, newServerProblem(fmt.Sprintf("job '%s' failed", curr.id)))
+		}
+	}()
+
+	return j.handler.renderReport(curr.request, curr.sources, curr.options, curr.limits, jobTypes[curr.jobType])
+}
+
+// expire removes the results which expired, every tenth of the TTL
+func (j *Jobs) expire() {
+
+	for now := range time.Tick(max(j.config.TTL/10, time.Second)) {
+		j.mutex.Lock()
+		for id, curr := range j.jobs {
+			if j.isExpired(curr, now) {
+				delete(j.jobs, id)
+			}
+		}
+		j.mutex.Unlock()
+	}
+}
+
+func (j *Jobs) isExpired(curr *job, now time.Time) bool {
+
+	return curr.status == JobStatusDone && now.Sub(curr.finished) > j.config.TTL
+}
+
+func newJobId() (string, error) {
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,178 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func createJobs(t *testing.T, ttl time.Duration) *internal.Jobs {
+
+	return internal.NewJobs(createHandler(t), internal.JobsConfig{Workers: 2, QueueSize: 10, TTL: ttl})
+}
+
+func createJob(t *testing.T, jobs *internal.Jobs, r *http.Request) *httptest.ResponseRecorder {
+
+	t.Helper()
+
+	r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant"})
+	w := httptest.NewRecorder()
+
+	jobs.Create(w, r)
+
+	return w
+}
+
+func getJob(jobs *internal.Jobs, tenantId string, id string) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(http.MethodGet, "/tenants/"+tenantId+"/jobs/"+id, nil)
+	r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: tenantId, internal.PathParamJobId: id})
+	w := httptest.NewRecorder()
+
+	jobs.Get(w, r)
+
+	return w
+}
+
+// waitForJob polls the job until it is done
+func waitForJob(t *testing.T, jobs *internal.Jobs, id string) *httptest.ResponseRecorder {
+
+	t.Helper()
+
+	var w *httptest.ResponseRecorder
+	require.Eventually(t, func() bool {
+		w = getJob(jobs, "test-tenant", id)
+		return w.Result().StatusCode != http.StatusAccepted
+	}, 10*time.Second, 10*time.Millisecond)
+
+	return w
+}
+
+func decodeJobStatus(t *testing.T, w *httptest.ResponseRecorder) internal.JobStatus {
+
+	t.Helper()
+
+	var res internal.JobStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+
+	return res
+}
+
+func TestJobs_Changelog(t *testing.T) {
+
+	jobs := createJobs(t, time.Hour)
+
+	r := createBreakingChangesUriRequest(t, url.Values{"type": {"changelog"}, "fail-on": {"ERR"}})
+	r.URL.Path = "/tenants/test-tenant/jobs"
+	w := createJob(t, jobs, r)
+
+	require.Equal(t, http.StatusAccepted, w.Result().StatusCode, w.Body.String())
+	status := decodeJobStatus(t, w)
+	require.Equal(t, "changelog", status.Type)
+	require.Equal(t, internal.JobStatusQueued, status.Status)
+	require.Equal(t, "/tenants/test-tenant/jobs/"+status.Id, w.Result().Header.Get("Location"))
+
+	w = waitForJob(t, jobs, path.Base(w.Result().Header.Get("Location")))
+
+	sync := httptest.NewRecorder()
+	createHandler(t).ChangelogFromUri(sync, createBreakingChangesUriRequest(t, url.Values{"fail-on": {"ERR"}}))
+
+	require.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	require.Equal(t, internal.JobStatusDone, w.Result().Header.Get(internal.HeaderJobStatus))
+	require.Equal(t, sync.Result().Header.Get("Content-Type"), w.Result().Header.Get("Content-Type"))
+	require.Equal(t, sync.Result().Header.Get(internal.HeaderErrors), w.Result().Header.Get(internal.HeaderErrors))
+	require.JSONEq(t, sync.Body.String(), w.Body.String())
+}
+
+func TestJobs_BreakingChangesFromFile(t *testing.T) {
+
+	jobs := createJobs(t, time.Hour)
+
+	r := createMultipartRequest(t, "/tenants/test-tenant/jobs?type=breaking-changes", readFile(t, "../data/openapi-test1.yaml"), readFile(t, "../data/openapi-test3.yaml"))
+	r.Header.Set("Accept", internal.HeaderTextPlain)
+	w := createJob(t, jobs, r)
+	require.Equal(t, http.StatusAccepted, w.Result().StatusCode, w.Body.String())
+
+	w = waitForJob(t, jobs, decodeJobStatus(t, w).Id)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderTextPlain, w.Result().Header.Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "request-parameter-removed")
+}
+
+func TestJobs_JsonConfig(t *testing.T) {
+
+	jobs := createJobs(t, time.Hour)
+
+	r := createJsonRequest(t, "/tenants/test-tenant/jobs?type=changelog", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision: internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+		Config:   &internal.JsonConfig{FailOn: "ERR"},
+	})
+	w := createJob(t, jobs, r)
+	require.Equal(t, http.StatusAccepted, w.Result().StatusCode, w.Body.String())
+
+	w = waitForJob(t, jobs, decodeJobStatus(t, w).Id)
+
+	require.Equal(t, http.StatusConflict, w.Result().StatusCode)
+}
+
+func TestJobs_LoadFails(t *testing.T) {
+
+	jobs := createJobs(t, time.Hour)
+
+	r := createMultipartRequest(t, "/tenants/test-tenant/jobs?type=diff", []byte("not a spec"), readFile(t, "../data/openapi-test3.yaml"))
+	w := createJob(t, jobs, r)
+	require.Equal(t, http.StatusAccepted, w.Result().StatusCode, w.Body.String())
+
+	w = waitForJob(t, jobs, decodeJobStatus(t, w).Id)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.InputBase, decodeProblem(t, w).Input)
+}
+
+func TestJobs_InvalidType(t *testing.T) {
+
+	w := createJob(t, createJobs(t, time.Hour), createBreakingChangesUriRequest(t, url.Values{"type": {"summary"}}))
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.ParamJobType, decodeProblem(t, w).Input)
+}
+
+func TestJobs_NotFound(t *testing.T) {
+
+	w := getJob(createJobs(t, time.Hour), "test-tenant", "missing")
+
+	require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypeJobNotFound, decodeProblem(t, w).Type)
+}
+
+func TestJobs_OtherTenant(t *testing.T) {
+
+	jobs := createJobs(t, time.Hour)
+	w := createJob(t, jobs, createBreakingChangesUriRequest(t, url.Values{"type": {"diff"}}))
+	id := decodeJobStatus(t, w).Id
+	waitForJob(t, jobs, id)
+
+	require.Equal(t, http.StatusNotFound, getJob(jobs, "other-tenant", id).Result().StatusCode)
+}
+
+func TestJobs_Expired(t *testing.T) {
+
+	jobs := createJobs(t, time.Millisecond)
+	w := createJob(t, jobs, createBreakingChangesUriRequest(t, url.Values{"type": {"diff"}}))
+	id := decodeJobStatus(t, w).Id
+
+	require.Eventually(t, func() bool {
+		return getJob(jobs, "test-tenant", id).Result().StatusCode == http.StatusNotFound
+	}, 10*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// JsonRequest is an application/json request body, see docs/openapi.yaml
+type JsonRequest struct {
+	Base       JsonSpecs      `json:"base,omitempty"`
+	Revision   JsonSpecs      `json:"revision,omitempty"`
+	Config     *JsonConfig    `json:"config,omitempty"`
+	Checks     *CheckerConfig `json:"checks,omitempty"`
+	Ignore     string         `json:"ignore,omitempty"`      // the ignore file of changes of all levels
+	ErrIgnore  string         `json:"err-ignore,omitempty"`  // the ignore file of ERR changes
+	WarnIgnore string         `json:"warn-ignore,omitempty"` // the ignore file of WARN changes
+}
+
+// JsonSpecs are the specs of one side of a JSON request, given as a single spec or as an array of specs to compose
+type JsonSpecs []JsonSpec
+
+func (s *JsonSpecs) UnmarshalJSON(data []byte) error {
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return json.Unmarshal(data, (*[]JsonSpec)(s))
+	}
+
+	var spec JsonSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	*s = JsonSpecs{spec}
+
+	return nil
+}
+
+// JsonSpec is a spec in a JSON request, given as exactly one of inline, base64 or url
+type JsonSpec struct {
+	Inline     json.RawMessage `json:"inline,omitempty"`     // the spec as a JSON object, or as a YAML or JSON string
+	Base64     string          `json:"base64,omitempty"`     // the base64 encoded spec or zip/tar.gz archive
+	Url        string          `json:"url,omitempty"`        // the URI of the spec
+	Entrypoint string          `json:"entrypoint,omitempty"` // the path of the root spec inside an archive
+}
+
+// JsonConfig holds the options of a JSON request, named like their query parameters
+type JsonConfig struct {
+	PathFilter              string   `json:"path-filter,omitempty"`
+	UnmatchPath             string   `json:"unmatch-path,omitempty"`
+	FilterExtension         string   `json:"filter-extension,omitempty"`
+	PathPrefixBase          string   `json:"path-prefix-base,omitempty"`
+	PathPrefixRevision      string   `json:"path-prefix-revision,omitempty"`
+	PathStripPrefixBase     string   `json:"path-strip-prefix-base,omitempty"`
+	PathStripPrefixRevision string   `json:"path-strip-prefix-revision,omitempty"`
+	ExcludeElements         []string `json:"exclude-elements,omitempty"` // an empty array excludes nothing, replacing the default
+	IncludePathParams       bool     `json:"include-path-params,omitempty"`
+	FlattenAllOf            bool     `json:"flatten-allof,omitempty"`
+	FlattenParams           bool     `json:"flatten-params,omitempty"`
+	CaseInsensitiveHeaders  bool     `json:"case-insensitive-headers,omitempty"`
+	FailOn                  string   `json:"fail-on,omitempty"`
+	Lang                    string   `json:"lang,omitempty"`
+	Template                string   `json:"template,omitempty"`
+}
+
+// values returns the options which were set, keyed by their query parameter names
+func (c *JsonConfig) values() url.Values {
+
+	res := url.Values{}
+	if c == nil {
+		return res
+	}
+
+	for key, value := range map[string]string{
+		ParamPathFilter:              c.PathFilter,
+		ParamUnmatchPath:             c.UnmatchPath,
+		ParamFilterExtension:         c.FilterExtension,
+		ParamPathPrefixBase:          c.PathPrefixBase,
+		ParamPathPrefixRevision:      c.PathPrefixRevision,
+		ParamPathStripPrefixBase:     c.PathStripPrefixBase,
+		ParamPathStripPrefixRevision: c.PathStripPrefixRevision,
+		ParamFailOn:                  c.FailOn,
+		ParamLang:                    c.Lang,
+		ParamTemplate:                c.Template,
+	} {
+		if value != "" {
+			res.Set(key, value)
+		}
+	}
+
+	for key, value := range map[string]bool{
+		ParamIncludePathParams:      c.IncludePathParams,
+		ParamFlattenAllOf:           c.FlattenAllOf,
+		ParamFlattenParams:          c.FlattenParams,
+		ParamCaseInsensitiveHeaders: c.CaseInsensitiveHeaders,
+	} {
+		if value {
+			res.Set(key, "true")
+		}
+	}
+
+	if c.ExcludeElements != nil {
+		res.Set(ParamExcludeElements, strings.Join(c.ExcludeElements, ","))
+	}
+
+	return res
+}
+
+// readJsonSources adds the specs of a JSON request body to sources.
+// The request's options are added to the options of the body, so they apply exactly like query parameters.
+func readJsonSources(r *http.Request, limits Limits, fetcher *Fetcher, sources SpecSources, options *bodyOptions) error {
+
+	var body JsonRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		return newRequestBodyProblem(fmt.Sprintf("failed to parse '%s' request", HeaderAppJson), err)
+	}
+
+	for _, input := range []string{InputBase, InputRevision} {
+		specs := body.Base
+		if input == InputRevision {
+			specs = body.Revision
+		}
+		for _, spec := range specs {
+			if err := addJsonSource(spec, input, limits, fetcher, sources); err != nil {
+				return err
+			}
+		}
+	}
+
+	ignores := map[string]string{InputIgnore: body.Ignore, InputErrIgnore: body.ErrIgnore, InputWarnIgnore: body.WarnIgnore}
+	for _, input := range ignoreInputs {
+		if data := ignores[input.name]; data != "" {
+			if err := options.setIgnore(input.name, []byte(data), limits); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := options.add(body.Config.values()); err != nil {
+		return err
+	}
+
+	return options.add(body.Checks.values())
+}
+
+func addJsonSource(spec JsonSpec, input string, limits Limits, fetcher *Fetcher, sources SpecSources) error {
+
+	given := 0
+	for _, set := range []bool{len(spec.Inline) > 0, spec.Base64 != "", spec.Url != ""} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("'%s' spec must have exactly one of 'inline', 'base64' and 'url'", input)).WithInput(input)
+	}
+
+	if spec.Url != "" {
+		if err := checkSpecUri(fetcher, spec.Url, input); err != nil {
+			return err
+		}
+		return sources.add(uriSource{input: input, uri: spec.Url}, limits)
+	}
+
+	data, err := getJsonSpecData(spec, input)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > limits.MaxSpecSize {
+		return NewProblem(http.StatusRequestEntityTooLarge, ProblemTypePayloadTooLarge, fmt.Sprintf("spec exceeds %d bytes", limits.MaxSpecSize)).WithInput(input)
+	}
+
+	return sources.add(dataSource{input: input, name: sources.getName(input, ""), entrypoint: spec.Entrypoint, data: data}, limits)
+}
+
+// getJsonSpecData returns the data of a base64 or inline spec, an inline spec is either the spec itself or a string holding it
+func getJsonSpecData(spec JsonSpec, input string) ([]byte, error) {
+
+	if spec.Base64 != "" {
+		data, err := base64.StdEncoding.DecodeString(spec.Base64)
+		if err != nil {
+			return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("failed to decode base64 '%s' spec with %v", input, err)).WithInput(input)
+		}
+		return data, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(spec.Inline, &text); err == nil {
+		return []byte(text), nil
+	}
+
+	return spec.Inline, nil
+}
@@ -0,0 +1,131 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func createJsonRequest(t *testing.T, path string, body any) *http.Request {
+
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	r, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", internal.HeaderAppJson)
+
+	return r
+}
+
+func TestChangelogFromFile_Json(t *testing.T) {
+
+	r := createJsonRequest(t, "/changelog", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision: internal.JsonSpecs{{Base64: base64.StdEncoding.EncodeToString(readFile(t, "../data/openapi-test3.yaml"))}},
+	})
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "response-success-status-removed")
+}
+
+func TestDiffFromFile_JsonInline(t *testing.T) {
+
+	inline, err := json.Marshal(string(readFile(t, "../data/openapi-test3.yaml")))
+	require.NoError(t, err)
+
+	r := createJsonRequest(t, "/diff", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Inline: json.RawMessage(`{"openapi":"3.0.0","info":{"title":"inline","version":"1.0.0"},"paths":{}}`)}},
+		Revision: internal.JsonSpecs{{Inline: inline}},
+	})
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+}
+
+func TestChangelogFromFile_JsonConfig(t *testing.T) {
+
+	r := createJsonRequest(t, "/changelog", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision: internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+		Config:   &internal.JsonConfig{PathFilter: "no-such-path"},
+	})
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.NotContains(t, w.Body.String(), "response-success-status-removed")
+}
+
+func TestChangelogFromFile_JsonOptionInQueryAndBody(t *testing.T) {
+
+	r := createJsonRequest(t, "/changelog", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision: internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+		Config:   &internal.JsonConfig{PathFilter: "/api"},
+	})
+	r.URL.RawQuery = "path-filter=/api"
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, "path-filter", decodeProblem(t, w).Input)
+}
+
+func TestChangelogFromFile_JsonConfigKeepsQuery(t *testing.T) {
+
+	r := createJsonRequest(t, "/changelog?format=json", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml")}},
+		Revision: internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+		Config:   &internal.JsonConfig{FailOn: "ERR", Lang: "ru"},
+	})
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	require.Equal(t, "ru", w.Result().Header.Get(internal.HeaderContentLanguage))
+	require.Equal(t, "format=json", r.URL.RawQuery)
+}
+
+func TestChangelogFromFile_JsonSpecWithTwoSources(t *testing.T) {
+
+	r := createJsonRequest(t, "/changelog", internal.JsonRequest{
+		Base:     internal.JsonSpecs{{Url: specUri("openapi-test1.yaml"), Base64: "b3BlbmFwaQ=="}},
+		Revision: internal.JsonSpecs{{Url: specUri("openapi-test3.yaml")}},
+	})
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeInvalidRequest, problem.Type)
+	require.Equal(t, internal.InputBase, problem.Input)
+}
+
+func TestChangelogFromFile_JsonUnknownField(t *testing.T) {
+
+	r := createJsonRequest(t, "/changelog", map[string]any{"base": map[string]string{"path": "/etc/passwd"}})
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypeInvalidRequest, decodeProblem(t, w).Type)
+}
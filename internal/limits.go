@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"net/http"
+
+	"github.com/oasdiff/go-common/env"
+)
+
+const (
+	DEFAULT_MAX_REQUEST_BODY_SIZE = 32 << 20 // 32 MB
+	DEFAULT_MAX_SPEC_SIZE         = 16 << 20 // 16 MB
+	DEFAULT_MAX_ARCHIVE_SIZE      = 64 << 20 // 64 MB
+	DEFAULT_MAX_ARCHIVE_FILES     = 1000
+	DEFAULT_MAX_COMPOSED_SPECS    = 100
+)
+
+// Limits bound the size of uploaded specs
+type Limits struct {
+	MaxRequestBodySize int64 // max size of the whole request body, in bytes
+	MaxSpecSize        int64 // max size of each spec, in bytes, archives are bounded by their compressed size
+	MaxArchiveSize     int64 // max total size of the files extracted from each archive, in bytes
+	MaxArchiveFiles    int   // max number of files in each archive
+	MaxComposedSpecs   int   // max number of specs per side in composed mode
+}
+
+// NewLimits returns the deployment limits, configured by environment variables
+func NewLimits() Limits {
+
+	return Limits{
+		MaxRequestBodySize: int64(env.GetIntWithDefault("MAX_REQUEST_BODY_SIZE", DEFAULT_MAX_REQUEST_BODY_SIZE)),
+		MaxSpecSize:        int64(env.GetIntWithDefault("MAX_SPEC_SIZE", DEFAULT_MAX_SPEC_SIZE)),
+		MaxArchiveSize:     int64(env.GetIntWithDefault("MAX_ARCHIVE_SIZE", DEFAULT_MAX_ARCHIVE_SIZE)),
+		MaxArchiveFiles:    env.GetIntWithDefault("MAX_ARCHIVE_FILES", DEFAULT_MAX_ARCHIVE_FILES),
+		MaxComposedSpecs:   env.GetIntWithDefault("MAX_COMPOSED_SPECS", DEFAULT_MAX_COMPOSED_SPECS),
+	}
+}
+
+// withTenant returns the limits overridden by the tenant settings
+func (l Limits) withTenant(settings *TenantSettings) Limits {
+
+	if settings.MaxRequestBodySize > 0 {
+		l.MaxRequestBodySize = settings.MaxRequestBodySize
+	}
+	if settings.MaxSpecSize > 0 {
+		l.MaxSpecSize = settings.MaxSpecSize
+	}
+	if settings.MaxArchiveSize > 0 {
+		l.MaxArchiveSize = settings.MaxArchiveSize
+	}
+	if settings.MaxArchiveFiles > 0 {
+		l.MaxArchiveFiles = settings.MaxArchiveFiles
+	}
+	if settings.MaxComposedSpecs > 0 {
+		l.MaxComposedSpecs = settings.MaxComposedSpecs
+	}
+
+	return l
+}
+
+// getLimits returns the limits applying to the tenant of the request
+func (h *Handler) getLimits(r *http.Request) Limits {
+	return h.limits.withTenant(h.getTenantSettings(r))
+}
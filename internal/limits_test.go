@@ -0,0 +1,84 @@
+package internal_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/ds"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+// settingsClient is a datastore client returning the given tenant settings
+type settingsClient struct {
+	ds.Client
+	settings internal.TenantSettings
+}
+
+func (c settingsClient) Get(kind ds.Kind, id string, dst interface{}) error {
+
+	if kind == internal.KindTenantSettings {
+		*dst.(*internal.TenantSettings) = c.settings
+	}
+
+	return nil
+}
+
+func TestReadSpecSources_SpecTooLarge(t *testing.T) {
+
+	r := createMultipartRequest(t, "/changelog", readFile(t, "../data/openapi-test1.yaml"), readFile(t, "../data/openapi-test3.yaml"))
+	w := httptest.NewRecorder()
+
+	_, _, err := internal.ReadSpecSources(w, r, internal.Limits{MaxRequestBodySize: 1 << 20, MaxSpecSize: 100}, createFetcher())
+
+	var problem *internal.Problem
+	require.ErrorAs(t, err, &problem)
+	require.Equal(t, http.StatusRequestEntityTooLarge, problem.Status)
+	require.Equal(t, internal.InputBase, problem.Input)
+}
+
+func TestReadSpecSources_BodyTooLarge(t *testing.T) {
+
+	r := createMultipartRequest(t, "/changelog", readFile(t, "../data/openapi-test1.yaml"), readFile(t, "../data/openapi-test3.yaml"))
+	w := httptest.NewRecorder()
+
+	// the body limit is reached while the base spec is streamed to the loader, which is no problem with the spec
+	_, _, err := internal.ReadSpecSources(w, r, internal.Limits{MaxRequestBodySize: 1000, MaxSpecSize: 1 << 20}, createFetcher())
+
+	var problem *internal.Problem
+	require.ErrorAs(t, err, &problem)
+	require.Equal(t, http.StatusRequestEntityTooLarge, problem.Status)
+	require.Equal(t, internal.InputBase, problem.Input)
+}
+
+func TestReadSpecSources_FormUrlEncoded(t *testing.T) {
+
+	r, err := http.NewRequest(http.MethodPost, "/changelog", bytes.NewReader([]byte("base=openapi%3A+3.0.0&revision=")))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", internal.HeaderAppFormUrlEncoded)
+	w := httptest.NewRecorder()
+
+	_, _, err = internal.ReadSpecSources(w, r, internal.NewLimits(), createFetcher())
+
+	var problem *internal.Problem
+	require.ErrorAs(t, err, &problem)
+	require.Equal(t, http.StatusBadRequest, problem.Status)
+	require.Equal(t, internal.InputRevision, problem.Input)
+}
+
+func TestChangelogFromFile_TenantLimits(t *testing.T) {
+
+	r := createMultipartRequest(t, "/changelog", readFile(t, "../data/openapi-test1.yaml"), readFile(t, "../data/openapi-test3.yaml"))
+	r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant"})
+	w := httptest.NewRecorder()
+
+	h := internal.NewHandler(settingsClient{settings: internal.TenantSettings{MaxRequestBodySize: 1000}}, nil, internal.NewLimits(), createFetcher(), nil)
+	h.ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypePayloadTooLarge, decodeProblem(t, w).Type)
+}
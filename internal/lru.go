@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru keeps values up to a total size, removing the least recently used values first
+type lru[V any] struct {
+	maxSize int64
+	sizeOf  func(V) int64
+
+	mutex sync.Mutex
+	list  *list.List // of *lruItem, most recently used first
+	items map[string]*list.Element
+	size  int64
+}
+
+type lruItem[V any] struct {
+	key   string
+	value V
+}
+
+func newLRU[V any](maxSize int64, sizeOf func(V) int64) *lru[V] {
+
+	return &lru[V]{
+		maxSize: maxSize,
+		sizeOf:  sizeOf,
+		list:    list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+func (c *lru[V]) get(key string) (V, bool) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.list.MoveToFront(elem)
+
+	return elem.Value.(*lruItem[V]).value, true
+}
+
+// put adds the value or replaces the value of the key, a value larger than the max size is not kept
+func (c *lru[V]) put(key string, value V) {
+
+	size := c.sizeOf(value)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeLocked(key)
+	if size > c.maxSize {
+		return
+	}
+
+	c.items[key] = c.list.PushFront(&lruItem[V]{key: key, value: value})
+	c.size += size
+
+	for c.size > c.maxSize {
+		c.removeLocked(c.list.Back().Value.(*lruItem[V]).key)
+	}
+}
+
+func (c *lru[V]) remove(key string) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeLocked(key)
+}
+
+func (c *lru[V]) removeLocked(key string) {
+
+	elem, ok := c.items[key]
+	if !ok {
+		return
+	}
+
+	c.list.Remove(elem)
+	delete(c.items, key)
+	c.size -= c.sizeOf(elem.Value.(*lruItem[V]).value)
+}
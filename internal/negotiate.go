@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"cmp"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/oasdiff/oasdiff/checker/localizations"
+)
+
+const (
+	// ParamFormat overrides the Accept header by format name, for links opened in a browser
+	ParamFormat = "format"
+	// ParamLang overrides the Accept-Language header
+	ParamLang = "lang"
+)
+
+// formats are the names of the media types which may be given as the format parameter
+var formats = map[string]string{
+	"json":     HeaderAppJson,
+	"yaml":     HeaderAppYaml,
+	"html":     HeaderTextHtml,
+	"text":     HeaderTextPlain,
+	"markdown": HeaderTextMarkdown,
+	"svg":      HeaderImageSvg,
+	"png":      HeaderImagePng,
+	"sarif":    HeaderAppSarif,
+	"junit":    HeaderAppJUnit,
+	"github":   HeaderTextGitHubActions,
+	"gitlab":   HeaderAppGitLab,
+}
+
+// media types of each kind of response, in order of preference, the first one is the default
+var (
+	reportMediaTypes    = []string{HeaderAppJson, HeaderAppYaml, HeaderTextHtml, HeaderTextPlain, HeaderTextMarkdown}
+	changelogMediaTypes = slices.Concat(reportMediaTypes, []string{HeaderAppSarif, HeaderAppJUnit, HeaderTextGitHubActions, HeaderAppGitLab})
+	summaryMediaTypes   = []string{HeaderAppJson, HeaderAppYaml, HeaderTextPlain}
+	badgeMediaTypes     = []string{HeaderImageSvg, HeaderImagePng}
+)
+
+// mediaRange is a media range of an Accept header with its quality
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// negotiateContentType returns the supported media type which was given as the format option, or else the one the Accept header prefers.
+// Media ranges may have wildcards and q-values, ties are broken by the order of supported.
+// An empty Accept header accepts the first supported media type, if none is acceptable the problem is a 406 listing the supported media types.
+func negotiateContentType(r *http.Request, format string, supported []string) (string, error) {
+
+	if format != "" {
+		return getFormatContentType(format, supported)
+	}
+
+	acceptHeader := GetAcceptHeader(r)
+	if strings.TrimSpace(acceptHeader) == "" {
+		return supported[0], nil
+	}
+
+	ranges := parseAccept(acceptHeader)
+	res, best := "", 0.0
+	for _, mediaType := range supported {
+		if quality := getQuality(ranges, mediaType); quality > best {
+			res, best = mediaType, quality
+		}
+	}
+
+	if res == "" {
+		return "", NewProblem(http.StatusNotAcceptable, ProblemTypeNotAcceptable,
+			fmt.Sprintf("none of the supported media types is acceptable, use one of '%s' or the '%s' parameter", strings.Join(supported, "', '"), ParamFormat))
+	}
+
+	return res, nil
+}
+
+func getFormatContentType(format string, supported []string) (string, error) {
+
+	mediaType, ok := formats[strings.ToLower(format)]
+	if !ok || !slices.Contains(supported, mediaType) {
+		var names []string
+		for name, curr := range formats {
+			if slices.Contains(supported, curr) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		return "", NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter,
+			fmt.Sprintf("unsupported format '%s', use one of %s", format, strings.Join(names, ", "))).WithInput(ParamFormat)
+	}
+
+	return mediaType, nil
+}
+
+// parseAccept returns the media ranges of an Accept header, invalid media ranges are skipped
+func parseAccept(acceptHeader string) []mediaRange {
+
+	var res []mediaRange
+	for _, item := range strings.Split(acceptHeader, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		res = append(res, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	return res
+}
+
+// getQuality returns the quality of the most specific media range matching the media type, zero if none matches
+func getQuality(ranges []mediaRange, mediaType string) float64 {
+
+	res, specificity := 0.0, -1
+	for _, curr := range ranges {
+		if currSpecificity := matchMediaRange(curr.mediaType, mediaType); currSpecificity > specificity {
+			res, specificity = curr.quality, currSpecificity
+		}
+	}
+
+	return res
+}
+
+// matchMediaRange returns how specifically the media range matches the media type: 2 for an exact match, 1 for 'type/*', 0 for '*/*' and -1 if it doesn't match
+func matchMediaRange(mediaRange string, mediaType string) int {
+
+	if mediaRange == mediaType {
+		return 2
+	}
+	if mediaRange == "*/*" {
+		return 0
+	}
+
+	if rangeType, ok := strings.CutSuffix(mediaRange, "/*"); ok && strings.HasPrefix(mediaType, rangeType+"/") {
+		return 1
+	}
+
+	return -1
+}
+
+// negotiateLanguage returns the supported language of the lang option, or else the one the Accept-Language header prefers.
+// Language ranges match supported languages exactly or by their primary subtag, so 'en-US' matches 'en' and 'pt' matches 'pt-br'.
+// If no language is acceptable, the tenant's default language is used, matched the same way, or else English.
+func negotiateLanguage(r *http.Request, lang string, tenantLanguage string) (string, error) {
+
+	supported := localizations.GetSupportedLanguages()
+
+	if lang != "" {
+		if res, ok := matchLanguage(strings.ToLower(lang), supported); ok {
+			return res, nil
+		}
+		return "", NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter,
+			fmt.Sprintf("unsupported language '%s', use one of %s", lang, strings.Join(supported, ", "))).WithInput(ParamLang)
+	}
+
+	for _, languageRange := range parseAcceptLanguage(GetAcceptLanguageHeader(r)) {
+		if res, ok := matchLanguage(languageRange, supported); ok {
+			return res, nil
+		}
+	}
+
+	if res, ok := matchLanguage(strings.ToLower(tenantLanguage), supported); ok {
+		return res, nil
+	}
+
+	return localizations.LangDefault, nil
+}
+
+// parseAcceptLanguage returns the lowercase language ranges of an Accept-Language header by descending quality, without unacceptable ranges and the '*' wildcard
+func parseAcceptLanguage(acceptLanguageHeader string) []string {
+
+	var ranges []mediaRange
+	for _, item := range strings.Split(acceptLanguageHeader, ",") {
+		tag, params, _ := strings.Cut(item, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality > 1 {
+				continue
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: tag, quality: quality})
+	}
+
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		return cmp.Compare(b.quality, a.quality)
+	})
+
+	res := make([]string, len(ranges))
+	for i, curr := range ranges {
+		res[i] = curr.mediaType
+	}
+
+	return res
+}
+
+// matchLanguage returns the supported language matching the lowercase language range exactly, or else by their primary subtag
+func matchLanguage(languageRange string, supported []string) (string, bool) {
+
+	if slices.Contains(supported, languageRange) {
+		return languageRange, true
+	}
+
+	primary, _, _ := strings.Cut(languageRange, "-")
+	for _, language := range supported {
+		if supportedPrimary, _, _ := strings.Cut(language, "-"); supportedPrimary == primary {
+			return language, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,155 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangelogFromUri_Accept(t *testing.T) {
+
+	for accept, expected := range map[string]string{
+		"":                                internal.HeaderAppJson,
+		"*/*":                             internal.HeaderAppJson,
+		"application/json; charset=utf-8": internal.HeaderAppJson,
+		"text/html,application/xhtml+xml,*/*;q=0.8": internal.HeaderTextHtml,
+		"text/*;q=0.5, application/yaml":            internal.HeaderAppYaml,
+		"text/*, text/plain;q=0.2":                  internal.HeaderTextHtml,
+		"application/json;q=0, */*;q=0.1":           internal.HeaderAppYaml,
+	} {
+		r := createBreakingChangesUriRequest(t, url.Values{})
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+
+		createHandler(t).ChangelogFromUri(w, r)
+
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode, accept)
+		require.Equal(t, expected, w.Result().Header.Get("Content-Type"), accept)
+	}
+}
+
+func TestChangelogFromUri_NotAcceptable(t *testing.T) {
+
+	r := createBreakingChangesUriRequest(t, url.Values{})
+	r.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromUri(w, r)
+
+	require.Equal(t, http.StatusNotAcceptable, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeNotAcceptable, problem.Type)
+	require.Contains(t, problem.Detail, internal.HeaderTextMarkdown)
+}
+
+func TestSummaryFromUri_Format(t *testing.T) {
+
+	r := createBreakingChangesUriRequest(t, url.Values{"format": {"yaml"}})
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+
+	createHandler(t).SummaryFromUri(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderAppYaml, w.Result().Header.Get("Content-Type"))
+}
+
+func TestSummaryFromUri_UnsupportedFormat(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).SummaryFromUri(w, createBreakingChangesUriRequest(t, url.Values{"format": {"html"}}))
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.ParamFormat, decodeProblem(t, w).Input)
+}
+
+func TestChangelogFromUri_AcceptLanguage(t *testing.T) {
+
+	for acceptLanguage, expected := range map[string]string{
+		"":                       "en",
+		"*":                      "en",
+		"ru":                     "ru",
+		"es-MX":                  "es",
+		"pt":                     "pt-br",
+		"PT-BR":                  "pt-br",
+		"de, ru;q=0.5, es;q=0.8": "es",
+		"ru;q=0.5, es;q=0.5":     "ru",
+		"es;q=0, ru;q=0.1":       "ru",
+		"de, fr":                 "en",
+	} {
+		r := createBreakingChangesUriRequest(t, url.Values{})
+		r.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+
+		createHandler(t).ChangelogFromUri(w, r)
+
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode, acceptLanguage)
+		require.Equal(t, expected, w.Result().Header.Get("Content-Language"), acceptLanguage)
+	}
+}
+
+func TestChangelogFromUri_Lang(t *testing.T) {
+
+	r := createBreakingChangesUriRequest(t, url.Values{"lang": {"ru"}})
+	r.Header.Set("Accept-Language", "es")
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromUri(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Equal(t, "ru", w.Result().Header.Get("Content-Language"))
+	require.Contains(t, w.Result().Header.Get("Vary"), "Accept-Language")
+}
+
+func TestDiffFromUri_UnsupportedLang(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).DiffFromUri(w, createBreakingChangesUriRequest(t, url.Values{"lang": {"de"}}))
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.ParamLang, decodeProblem(t, w).Input)
+}
+
+func TestChangelogFromUri_TenantLanguageMatched(t *testing.T) {
+
+	for language, expected := range map[string]string{
+		"pt-BR": "pt-br",
+		"en-US": "en",
+		"ES":    "es",
+		"de":    "en",
+	} {
+		r := createBreakingChangesUriRequest(t, url.Values{})
+		r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant"})
+		w := httptest.NewRecorder()
+
+		internal.NewHandler(settingsClient{settings: internal.TenantSettings{Language: language}}, nil, internal.NewLimits(), createFetcher(), nil).ChangelogFromUri(w, r)
+
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode, language)
+		require.Equal(t, expected, w.Result().Header.Get("Content-Language"), language)
+	}
+}
+
+func TestChangelogFromUri_TenantLanguage(t *testing.T) {
+
+	for acceptLanguage, expected := range map[string]string{
+		"":   "es",
+		"de": "es",
+		"ru": "ru",
+	} {
+		r := createBreakingChangesUriRequest(t, url.Values{})
+		r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant"})
+		r.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+
+		internal.NewHandler(settingsClient{settings: internal.TenantSettings{Language: "es"}}, nil, internal.NewLimits(), createFetcher(), nil).ChangelogFromUri(w, r)
+
+		require.Equal(t, http.StatusCreated, w.Result().StatusCode, acceptLanguage)
+		require.Equal(t, expected, w.Result().Header.Get("Content-Language"), acceptLanguage)
+	}
+}
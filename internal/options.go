@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/oasdiff/oasdiff/checker"
+	"github.com/oasdiff/oasdiff/diff"
+	"github.com/oasdiff/oasdiff/load"
+)
+
+// ParamComposed forces composed mode, which is otherwise implied by more than one spec per side
+const ParamComposed = "composed"
+
+// Options are the options of a report request, parsed once from its query parameters and from the config, checks and ignore files given in its body.
+// They are passed explicitly to everything the report depends on, so the request is never rewritten and queued jobs and cache keys see exactly the options the report is rendered with.
+type Options struct {
+	Diff                   *diff.Config // validated, see getDiffConfig for a copy which may be changed
+	FlattenAllOf           bool
+	FlattenParams          bool
+	CaseInsensitiveHeaders bool
+	Composed               bool
+
+	Levels                map[string]checker.Level // check id to the level given by severity or disable, NONE disables the check
+	DeprecationDaysBeta   *uint                    // overrides the tenant's deprecation policy if given
+	DeprecationDaysStable *uint                    // overrides the tenant's deprecation policy if given
+
+	FailOn   checker.Level // NONE if not given
+	Format   string        // overrides the Accept header if given, validated when the content type is negotiated
+	Lang     string        // overrides the Accept-Language header if given, validated when the language is negotiated
+	Template string        // the name of the tenant's template, if given
+
+	ignores map[string]ignoreSource // keyed by input, see ignoreInputs
+}
+
+// NewOptions parses the options given as values named like the query parameters, invalid values are problems attributed to their parameter
+func NewOptions(values url.Values) (*Options, error) {
+
+	res := &Options{
+		Format:   getOption(values, ParamFormat),
+		Lang:     getOption(values, ParamLang),
+		Template: getOption(values, ParamTemplate),
+		ignores:  map[string]ignoreSource{},
+	}
+
+	var err error
+	if res.Diff, err = newDiffConfig(values); err != nil {
+		return nil, err
+	}
+
+	for key, value := range map[string]*bool{
+		ParamFlattenAllOf:           &res.FlattenAllOf,
+		ParamFlattenParams:          &res.FlattenParams,
+		ParamCaseInsensitiveHeaders: &res.CaseInsensitiveHeaders,
+		ParamComposed:               &res.Composed,
+	} {
+		if *value, err = getBoolOption(values, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if res.Levels, err = getCheckLevels(values); err != nil {
+		return nil, err
+	}
+	if res.DeprecationDaysBeta, err = getDaysOption(values, ParamDeprecationDaysBeta); err != nil {
+		return nil, err
+	}
+	if res.DeprecationDaysStable, err = getDaysOption(values, ParamDeprecationDaysStable); err != nil {
+		return nil, err
+	}
+
+	if res.FailOn, err = getFailOn(values); err != nil {
+		return nil, err
+	}
+
+	for _, input := range ignoreInputs {
+		if uri := getOption(values, input.name); uri != "" {
+			res.ignores[input.name] = ignoreSource{uri: uri}
+		}
+	}
+
+	return res, nil
+}
+
+// newRequestOptions parses the options of a request, an option may not be given both as a query parameter and in the request body
+func newRequestOptions(r *http.Request, body *bodyOptions) (*Options, error) {
+
+	values := maps.Clone(r.URL.Query())
+	for key, curr := range body.values {
+		if values.Has(key) {
+			return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("option '%s' given both as a query parameter and in the request body", key)).WithInput(key)
+		}
+		values[key] = curr
+	}
+
+	res, err := NewOptions(values)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, input := range ignoreInputs {
+		source, ok := body.ignores[input.name]
+		if !ok {
+			continue
+		}
+		if _, ok := res.ignores[input.name]; ok {
+			return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("'%s' file given both as a uri and in the request body", input.name)).WithInput(input.name)
+		}
+		res.ignores[input.name] = source
+	}
+
+	return res, nil
+}
+
+// getDiffConfig returns a copy of the diff config, which may be changed without changing the options
+func (o *Options) getDiffConfig() *diff.Config {
+
+	res := *o.Diff
+	res.ExcludeElements = maps.Clone(o.Diff.ExcludeElements)
+
+	return &res
+}
+
+// getLoadOptions returns the options which preprocess the specs after loading them, like the oasdiff CLI's flatten-allof, flatten-params and case-insensitive-headers flags
+func (o *Options) getLoadOptions() []load.Option {
+
+	return []load.Option{
+		load.GetOption(load.WithFlattenAllOf(), o.FlattenAllOf),
+		load.GetOption(load.WithFlattenParams(), o.FlattenParams),
+		load.GetOption(load.WithLowercaseHeaders(), o.CaseInsensitiveHeaders),
+	}
+}
+
+// NewCheckerConfig returns the config of the checks with the levels of the options, the deprecation days of the options override the given default deprecation policy
+func (o *Options) NewCheckerConfig(deprecationDays DeprecationDays) *checker.Config {
+
+	if o.DeprecationDaysBeta != nil {
+		deprecationDays.Beta = *o.DeprecationDaysBeta
+	}
+	if o.DeprecationDaysStable != nil {
+		deprecationDays.Stable = *o.DeprecationDaysStable
+	}
+
+	return checker.NewConfig(checker.GetAllChecks()).WithSeverityLevels(o.Levels).WithDeprecation(deprecationDays.Beta, deprecationDays.Stable)
+}
+
+// bodyOptions are the options given in the request body, as the config, checks and ignore fields of a JSON request or as checks and ignore parts
+type bodyOptions struct {
+	values  url.Values              // named like the query parameters
+	ignores map[string]ignoreSource // keyed by input, see ignoreInputs
+}
+
+func newBodyOptions() *bodyOptions {
+
+	return &bodyOptions{values: url.Values{}, ignores: map[string]ignoreSource{}}
+}
+
+// add adds options named like the query parameters, an option may be given only once in the body
+func (o *bodyOptions) add(values url.Values) error {
+
+	for key, curr := range values {
+		if o.values.Has(key) {
+			return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("option '%s' given more than once in the request body", key)).WithInput(key)
+		}
+		o.values[key] = curr
+	}
+
+	return nil
+}
+
+// setIgnore sets the ignore file of the input given in the body, of at most the max spec size
+func (o *bodyOptions) setIgnore(input string, data []byte, limits Limits) error {
+
+	if _, ok := o.ignores[input]; ok {
+		return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("more than one '%s' file in request", input)).WithInput(input)
+	}
+	if int64(len(data)) > limits.MaxSpecSize {
+		return newIgnoreFileTooLargeProblem(input, limits)
+	}
+	o.ignores[input] = ignoreSource{data: data}
+
+	return nil
+}
+
+// getOption returns the first value of an option, or an empty string if it was not given
+func getOption(values url.Values, key string) string {
+
+	if curr, ok := values[key]; ok {
+		return curr[0]
+	}
+
+	return ""
+}
+
+// getBoolOption returns the value of a boolean option, false if it was not given
+func getBoolOption(values url.Values, key string) (bool, error) {
+
+	value := getOption(values, key)
+	if value == "" {
+		return false, nil
+	}
+
+	res, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("failed to parse '%s' with %v", key, err)).WithInput(key)
+	}
+
+	return res, nil
+}
+
+// getDaysOption returns the number of days of an option, or nil if it was not given
+func getDaysOption(values url.Values, key string) (*uint, error) {
+
+	value := getOption(values, key)
+	if value == "" {
+		return nil, nil
+	}
+
+	res, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter, fmt.Sprintf("failed to parse '%s' as a number of days with %v", key, err)).WithInput(key)
+	}
+	days := uint(res)
+
+	return &days, nil
+}
+
+// getListOption returns the values of an option which may be repeated or comma separated
+func getListOption(values url.Values, key string) []string {
+
+	var res []string
+	for _, value := range values[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res = append(res, item)
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const HeaderAppProblemJson = "application/problem+json"
+
+// problem types are stable identifiers clients can rely on, see docs/openapi.yaml
+const (
+	ProblemTypeMissingParameter     = "https://api.oasdiff.com/problems/missing-parameter"
+	ProblemTypeInvalidParameter     = "https://api.oasdiff.com/problems/invalid-parameter"
+	ProblemTypeInvalidRequest       = "https://api.oasdiff.com/problems/invalid-request"
+	ProblemTypePayloadTooLarge      = "https://api.oasdiff.com/problems/payload-too-large"
+	ProblemTypeUnsupportedMediaType = "https://api.oasdiff.com/problems/unsupported-media-type"
+	ProblemTypeNotAcceptable        = "https://api.oasdiff.com/problems/not-acceptable"
+	ProblemTypeSpecLoadFailed       = "https://api.oasdiff.com/problems/spec-load-failed"
+	ProblemTypeUriNotAllowed        = "https://api.oasdiff.com/problems/uri-not-allowed"
+	ProblemTypePathConflict         = "https://api.oasdiff.com/problems/path-conflict"
+	ProblemTypeDiffFailed           = "https://api.oasdiff.com/problems/diff-failed"
+	ProblemTypeRenderFailed         = "https://api.oasdiff.com/problems/render-failed"
+	ProblemTypeTemplateNotFound     = "https://api.oasdiff.com/problems/template-not-found"
+	ProblemTypeInvalidTemplate      = "https://api.oasdiff.com/problems/invalid-template"
+	ProblemTypeJobNotFound          = "https://api.oasdiff.com/problems/job-not-found"
+	ProblemTypeJobQueueFull         = "https://api.oasdiff.com/problems/job-queue-full"
+	ProblemTypePreconditionFailed   = "https://api.oasdiff.com/problems/precondition-failed"
+	ProblemTypeInternal             = "https://api.oasdiff.com/problems/internal"
+)
+
+var problemTitles = map[string]string{
+	ProblemTypeMissingParameter:     "Missing parameter",
+	ProblemTypeInvalidParameter:     "Invalid parameter",
+	ProblemTypeInvalidRequest:       "Invalid request",
+	ProblemTypePayloadTooLarge:      "Payload too large",
+	ProblemTypeUnsupportedMediaType: "Unsupported media type",
+	ProblemTypeNotAcceptable:        "Not acceptable",
+	ProblemTypeSpecLoadFailed:       "Failed to load spec",
+	ProblemTypeUriNotAllowed:        "URI not allowed",
+	ProblemTypePathConflict:         "Conflicting paths in composed specs",
+	ProblemTypeDiffFailed:           "Failed to compare specs",
+	ProblemTypeRenderFailed:         "Failed to render report",
+	ProblemTypeTemplateNotFound:     "Template not found",
+	ProblemTypeInvalidTemplate:      "Invalid template",
+	ProblemTypeJobNotFound:          "Job not found",
+	ProblemTypeJobQueueFull:         "Job queue full",
+	ProblemTypePreconditionFailed:   "Precondition failed",
+	ProblemTypeInternal:             "Internal server error",
+}
+
+// inputs reported in problems
+const (
+	InputBase     = "base"
+	InputRevision = "revision"
+)
+
+// Problem is an RFC 7807 problem details object, it is also an error so it can be returned by helpers
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Input  string `json:"input,omitempty"` // the request input that failed: base, revision or a parameter name
+}
+
+func NewProblem(status int, problemType string, detail string) *Problem {
+
+	return &Problem{
+		Type:   problemType,
+		Title:  problemTitles[problemType],
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// newServerProblem reports a server fault, as opposed to a problem with the request
+func newServerProblem(detail string) *Problem {
+	return NewProblem(http.StatusInternalServerError, ProblemTypeInternal, detail)
+}
+
+// newRequestBodyProblem classifies a failure to read the request body as oversized (413) or malformed (400)
+func newRequestBodyProblem(detail string, err error) *Problem {
+
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) || errors.Is(err, multipart.ErrMessageTooLarge) {
+		return NewProblem(http.StatusRequestEntityTooLarge, ProblemTypePayloadTooLarge, fmt.Sprintf("%s with '%v'", detail, err))
+	}
+
+	return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest, fmt.Sprintf("%s with '%v'", detail, err))
+}
+
+// newSpecLoadProblem classifies a failure to load a spec, invalid or unreachable specs are bad input (400)
+// while failing to read an existing file is a server fault (500)
+func newSpecLoadProblem(err error, input string) *Problem {
+
+	if errors.Is(err, ErrUriNotAllowed) {
+		return NewProblem(http.StatusBadRequest, ProblemTypeUriNotAllowed, err.Error()).WithInput(input)
+	}
+
+	var pathError *fs.PathError
+	if errors.As(err, &pathError) && !errors.Is(err, fs.ErrNotExist) {
+		return NewProblem(http.StatusInternalServerError, ProblemTypeInternal, fmt.Sprintf("failed to read spec with %v", err)).WithInput(input)
+	}
+
+	return NewProblem(http.StatusBadRequest, ProblemTypeSpecLoadFailed, fmt.Sprintf("failed to load spec with %v", err)).WithInput(input)
+}
+
+// WithInput sets the request input that caused the problem
+func (p *Problem) WithInput(input string) *Problem {
+
+	p.Input = input
+	return p
+}
+
+func (p *Problem) Error() string {
+
+	if p.Input == "" {
+		return fmt.Sprintf("%s: %s", p.Title, p.Detail)
+	}
+
+	return fmt.Sprintf("%s (%s): %s", p.Title, p.Input, p.Detail)
+}
+
+// writeProblem writes the error as a problem details response, errors which are not problems are reported as internal errors
+func writeProblem(w http.ResponseWriter, err error) {
+
+	var problem *Problem
+	if !errors.As(err, &problem) {
+		problem = NewProblem(http.StatusInternalServerError, ProblemTypeInternal, err.Error())
+	}
+
+	if problem.Status >= http.StatusInternalServerError {
+		log.Error(problem)
+	} else {
+		log.Info(problem)
+	}
+
+	w.Header().Set(HeaderContentType, HeaderAppProblemJson)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
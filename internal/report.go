@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"github.com/oasdiff/oasdiff/checker"
+	"github.com/oasdiff/oasdiff/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// report is a kind of report written from the specs of a request
+type report struct {
+	name       string
+	mediaTypes []string // the built-in formats of the report
+	changelog  bool     // whether the report is a changelog, which depends on templates, fail-on and ignore files
+	write      func(h *Handler, w http.ResponseWriter, r *http.Request, specs *Specs, options *Options)
+}
+
+var (
+	reportDiff = report{name: "diff", mediaTypes: reportMediaTypes, write: (*Handler).writeDiff}
+
+	reportChangelog = report{name: "changelog", mediaTypes: changelogMediaTypes, changelog: true, write: func(h *Handler, w http.ResponseWriter, r *http.Request, specs *Specs, options *Options) {
+		h.getChangelog(w, r, specs, options, CHANGELOG_LEVEL)
+	}}
+
+	reportBreakingChanges = report{name: "breaking-changes", mediaTypes: changelogMediaTypes, changelog: true, write: func(h *Handler, w http.ResponseWriter, r *http.Request, specs *Specs, options *Options) {
+		h.getChangelog(w, r, specs, options, BREAKING_LEVEL)
+	}}
+
+	reportSummary = report{name: "summary", mediaTypes: summaryMediaTypes, write: (*Handler).writeSummary}
+)
+
+// writeReport reads the request's specs and writes the report, with an entity tag which the request's If-None-Match is matched against
+func (h *Handler) writeReport(w http.ResponseWriter, r *http.Request, kind report) {
+
+	r = h.withTenantSettings(r)
+	limits := h.getLimits(r)
+
+	sources, options, err := ReadSpecSources(w, r, limits, h.fetcher)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	h.renderReport(r, sources, options, limits, kind).writeTo(w, r)
+}
+
+// renderReport loads the specs and renders the report, or returns the cached report if the same specs were already reported the same way.
+// Specs given in the request are looked up before resolving them. Fetched specs are looked up once loaded, by the documents fetched, which saves comparing and rendering them again.
+func (h *Handler) renderReport(r *http.Request, sources SpecSources, options *Options, limits Limits, kind report) *recordedResponse {
+
+	var key string
+	if !sources.isFetched() {
+		key = h.getCacheKey(r, sources, options, limits, kind, nil)
+		if cached, ok := h.getCachedReport(key); ok {
+			return cached
+		}
+	}
+
+	res := newRecordedResponse()
+	fetcher := h.fetcher.withRecord()
+	if specs, err := resolveSpecSources(sources, options, limits, fetcher); err != nil {
+		writeProblem(res, err)
+	} else {
+		if sources.isFetched() {
+			key = h.getCacheKey(r, sources, options, limits, kind, fetcher.getFetched())
+			if cached, ok := h.getCachedReport(key); ok {
+				return cached
+			}
+		}
+		kind.write(h, res, r, specs, options)
+	}
+	res.setETag()
+
+	if key != "" && res.isReport() {
+		h.cache.put(key, res)
+	}
+
+	return res
+}
+
+func (h *Handler) getCachedReport(key string) (*recordedResponse, bool) {
+
+	if key == "" {
+		return nil, false
+	}
+
+	return h.cache.get(key)
+}
+
+// cacheKey is everything a report depends on, reports with the same key are identical
+type cacheKey struct {
+	Version      string                   `json:"version"`
+	Report       string                   `json:"report"`
+	Specs        []cacheKeySpec           `json:"specs"`
+	Fetched      map[string]string        `json:"fetched,omitempty"`
+	Limits       Limits                   `json:"limits"` // a report loaded within higher limits may exceed those of another tenant
+	Composed     bool                     `json:"composed"`
+	LoadOptions  []bool                   `json:"load-options"`
+	Diff         *diff.Config             `json:"diff"`
+	BetaDays     uint                     `json:"beta-days"`
+	StableDays   uint                     `json:"stable-days"`
+	Levels       map[string]checker.Level `json:"levels"`
+	ContentType  string                   `json:"content-type"`
+	Language     string                   `json:"language"`
+	FailOn       checker.Level            `json:"fail-on,omitempty"`
+	Ignores      map[string]string        `json:"ignores,omitempty"`
+	TemplateHash string                   `json:"template,omitempty"`
+}
+
+type cacheKeySpec struct {
+	Input      string `json:"input"`
+	Name       string `json:"name"`
+	Entrypoint string `json:"entrypoint,omitempty"`
+	Hash       string `json:"hash,omitempty"` // of the spec or archive given in the request, the content of a URI is in the fetched documents
+}
+
+// getCacheKey returns the hash of the request's specs and of all the options of the report, or an empty key if the report can't be cached.
+// Specs given as URIs, and remote refs, are hashed by the documents fetched while loading them, so fetched is nil only if the sources fetch nothing, see SpecSources.isFetched.
+// Reports whose content type, language or template can't be resolved aren't cached, so that their problems are reported as usual.
+func (h *Handler) getCacheKey(r *http.Request, sources SpecSources, options *Options, limits Limits, kind report, fetched map[string]string) string {
+
+	if h.cache == nil || fetched == nil && sources.isFetched() {
+		return ""
+	}
+
+	key := cacheKey{Version: getBuildVersion(), Report: kind.name, Fetched: fetched, Limits: limits}
+
+	for _, input := range []string{InputBase, InputRevision} {
+		for _, source := range sources[input] {
+			switch source := source.(type) {
+			case dataSource:
+				key.Specs = append(key.Specs, cacheKeySpec{Input: source.input, Name: source.name, Entrypoint: source.entrypoint, Hash: getHash(source.data)})
+			case streamSource:
+				key.Specs = append(key.Specs, cacheKeySpec{Input: source.input, Name: source.name, Hash: source.hash})
+			case archiveSource:
+				key.Specs = append(key.Specs, cacheKeySpec{Input: source.input, Name: source.name, Entrypoint: source.entrypoint, Hash: getHash(source.data)})
+			case uriSource:
+				key.Specs = append(key.Specs, cacheKeySpec{Input: source.input, Name: source.uri})
+			default:
+				return ""
+			}
+		}
+	}
+
+	key.Composed = options.Composed
+	key.LoadOptions = []bool{options.FlattenAllOf, options.FlattenParams, options.CaseInsensitiveHeaders}
+	key.Diff = options.Diff
+
+	checkerConfig := options.NewCheckerConfig(h.getTenantSettings(r).getDeprecationDays())
+	key.BetaDays, key.StableDays, key.Levels = checkerConfig.MinSunsetBetaDays, checkerConfig.MinSunsetStableDays, checkerConfig.LogLevels
+
+	var err error
+	if key.Language, err = negotiateLanguage(r, options.Lang, h.getTenantSettings(r).Language); err != nil {
+		return ""
+	}
+
+	if !kind.changelog {
+		if key.ContentType, err = negotiateContentType(r, options.Format, kind.mediaTypes); err != nil {
+			return ""
+		}
+		return getKeyHash(key)
+	}
+
+	tmpl, err := h.getRequestTemplate(r, options)
+	if err != nil {
+		return ""
+	}
+	if tmpl != nil {
+		key.TemplateHash = getHash([]byte(tmpl.MediaType + "\n" + tmpl.Body))
+	}
+
+	if key.ContentType, err = getChangelogContentType(r, options, tmpl); err != nil {
+		return ""
+	}
+
+	key.FailOn = options.FailOn
+
+	for input, source := range options.ignores {
+		// an ignore file given as a URI may change, like a spec
+		if source.uri != "" {
+			return ""
+		}
+		if key.Ignores == nil {
+			key.Ignores = map[string]string{}
+		}
+		key.Ignores[input] = getHash(source.data)
+	}
+
+	return getKeyHash(key)
+}
+
+func getKeyHash(key cacheKey) string {
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		log.Warnf("failed to json encode cache key with %v", err)
+		return ""
+	}
+
+	return getHash(data)
+}
+
+func getHash(data []byte) string {
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// getBuildVersion identifies the build, so that reports cached on disk by an earlier version of the service or of oasdiff are not reused
+var getBuildVersion = sync.OnceValue(func() string {
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	res := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			res += " " + setting.Value
+		}
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/oasdiff/oasdiff" {
+			res += " " + dep.Version
+		}
+	}
+
+	return res
+})
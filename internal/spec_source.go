@@ -0,0 +1,520 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/oasdiff/oasdiff/load"
+)
+
+// media types of a single spec sent as the raw request body
+var rawSpecMediaTypes = []string{HeaderAppYaml, HeaderAppXYaml, HeaderTextYaml, HeaderAppOpenApi, HeaderAppOpenApiJson}
+
+// SpecSource is a spec given in a request: a URI, an uploaded file or archive, a form field or the raw request body.
+// All endpoints resolve sources the same way, so the same input yields the same spec, version info and errors.
+type SpecSource interface {
+	// Input returns the request input the spec was given as: base or revision
+	Input() string
+	// Load resolves the source into specs preprocessed by the options, only archives with a glob entrypoint resolve into more than one spec.
+	// Specs given as URIs and remote refs are fetched with the fetcher. Errors are problems attributed to the source's input.
+	Load(fetcher *Fetcher, options ...load.Option) ([]*load.SpecInfo, error)
+}
+
+// uriSource is a spec given as a URI, the spec and its external refs are fetched with the fetcher
+type uriSource struct {
+	input string
+	uri   string
+}
+
+func (s uriSource) Input() string { return s.input }
+
+func (s uriSource) Load(fetcher *Fetcher, options ...load.Option) ([]*load.SpecInfo, error) {
+
+	res, err := load.NewSpecInfo(fetcher.NewLoader(), load.NewSource(s.uri), options...)
+	if err != nil {
+		return nil, newSpecLoadProblem(err, s.input)
+	}
+
+	return []*load.SpecInfo{res}, nil
+}
+
+// dataSource is a spec given in the request body, external refs are not allowed since there is no location to resolve them against
+type dataSource struct {
+	input      string
+	name       string // the name of the spec in reports: the uploaded file name or the input
+	entrypoint string // the entrypoint in case the data is an archive
+	data       []byte
+}
+
+func (s dataSource) Input() string { return s.input }
+
+func (s dataSource) Load(_ *Fetcher, options ...load.Option) ([]*load.SpecInfo, error) {
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = false
+
+	res, err := load.NewSpecInfo(dataLoader{Loader: loader, data: s.data}, load.NewSource("-"), options...)
+	if err != nil {
+		return nil, newSpecLoadProblem(err, s.input)
+	}
+	res.Url = s.name
+
+	return []*load.SpecInfo{res}, nil
+}
+
+// dataLoader is a load.Loader that loads the spec given as data when reading from stdin
+type dataLoader struct {
+	*openapi3.Loader
+	data []byte
+}
+
+func (l dataLoader) LoadFromStdin() (*openapi3.T, error) {
+	return l.LoadFromData(l.data)
+}
+
+// streamSource is a spec streamed from the request body to the loader as the body is read, so that the request keeps the loaded spec rather than its bytes.
+// It is loaded with the load options of the query, which are the only load options of a multipart, form or raw request.
+type streamSource struct {
+	input string
+	name  string // the name of the spec in reports: the uploaded file name or the input
+	hash  string // of the bytes read, for the cache key
+	spec  *load.SpecInfo
+	err   error // failing to load the spec is reported when the sources are resolved, like for the other sources
+}
+
+func (s streamSource) Input() string { return s.input }
+
+func (s streamSource) Load(_ *Fetcher, _ ...load.Option) ([]*load.SpecInfo, error) {
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []*load.SpecInfo{s.spec}, nil
+}
+
+// readerLoader is a load.Loader that loads the spec streamed from a reader when reading from stdin
+type readerLoader struct {
+	*openapi3.Loader
+	reader io.Reader
+}
+
+func (l readerLoader) LoadFromStdin() (*openapi3.T, error) {
+	return l.LoadFromIoReader(l.reader)
+}
+
+// SpecSources are the sources of a request keyed by input, a side with more than one source is composed
+type SpecSources map[string][]SpecSource
+
+// isFetched tells whether loading the sources may fetch documents: a spec given as a URI, or an archive which may have remote refs
+func (s SpecSources) isFetched() bool {
+
+	for _, sources := range s {
+		for _, source := range sources {
+			switch source.(type) {
+			case uriSource, archiveSource:
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// add adds a source, sides are bounded by the max number of composed specs
+func (s SpecSources) add(source SpecSource, limits Limits) error {
+
+	input := source.Input()
+	if len(s[input]) >= limits.MaxComposedSpecs {
+		return newTooManySpecsProblem(input, limits)
+	}
+	s[input] = append(s[input], source)
+
+	return nil
+}
+
+// getName returns the name of the next spec of a side: its file name, or else the input.
+// A name another spec of the side has is numbered with the spec's position on the side, so that reports and conflicts tell the specs apart.
+func (s SpecSources) getName(input string, fileName string) string {
+
+	name := fileName
+	if name == "" {
+		name = input
+	}
+	if !s.hasName(input, name) {
+		return name
+	}
+
+	return fmt.Sprintf("%s#%d", name, len(s[input])+1)
+}
+
+func (s SpecSources) hasName(input string, name string) bool {
+
+	for _, source := range s[input] {
+		var curr string
+		switch source := source.(type) {
+		case dataSource:
+			curr = source.name
+		case streamSource:
+			curr = source.name
+		case archiveSource:
+			curr = source.name
+		}
+		if curr == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newTooManySpecsProblem(input string, limits Limits) *Problem {
+	return NewProblem(http.StatusRequestEntityTooLarge, ProblemTypePayloadTooLarge, fmt.Sprintf("more than %d '%s' specs in request", limits.MaxComposedSpecs, input)).WithInput(input)
+}
+
+// ReadSpecSources returns the base and revision sources of the request and its options.
+// Each spec is given as a URI query parameter or in the request body, as a multipart part, a form field, a JSON request or the raw body,
+// specs in the body which are zip or tar.gz archives are detected by their content.
+// A side may have several specs, given as repeated parameters or parts, which are composed.
+// Options are given as query parameters, or in the body as a JSON request's config or as checks and ignore parts, see NewOptions.
+// Errors are problems classified as bad input (400), oversized input (413), unsupported media type (415) or server faults (500).
+func ReadSpecSources(w http.ResponseWriter, r *http.Request, limits Limits, fetcher *Fetcher) (SpecSources, *Options, error) {
+
+	sources := SpecSources{}
+	for _, input := range []string{InputBase, InputRevision} {
+		for _, uri := range r.URL.Query()[input] {
+			if err := checkSpecUri(fetcher, uri, input); err != nil {
+				return nil, nil, err
+			}
+			if err := sources.add(uriSource{input: input, uri: uri}, limits); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	body := newBodyOptions()
+	if hasBody(r) {
+		// a multipart, form or raw body can't give load options, so its specs are loaded as they are read with those of the query
+		queryOptions, err := NewOptions(r.URL.Query())
+		if err != nil {
+			return nil, nil, err
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limits.MaxRequestBodySize)
+		if err := readBodySources(r, limits, fetcher, queryOptions.getLoadOptions(), sources, body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for input, inputSources := range sources {
+		for i, source := range inputSources {
+			if data, ok := source.(dataSource); ok && isArchive(data.data) {
+				entrypoint := data.entrypoint
+				if entrypoint == "" {
+					entrypoint = getEntrypoint(r, input)
+				}
+				inputSources[i] = archiveSource{limits: limits, input: input, name: data.name, entrypoint: entrypoint, data: data.data}
+			}
+		}
+	}
+
+	for _, input := range []string{InputBase, InputRevision} {
+		if len(sources[input]) == 0 {
+			return nil, nil, NewProblem(http.StatusBadRequest, ProblemTypeMissingParameter,
+				fmt.Sprintf("no '%s' spec in request, give it as a uri or in the request body", input)).WithInput(input)
+		}
+	}
+
+	options, err := newRequestOptions(r, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sources, options, nil
+}
+
+// LoadSpecSources resolves the sources into specs preprocessed by the options, fetching specs given as URIs and remote refs with the fetcher.
+// Composed mode is forced by composed or else implied by more than one spec per side.
+func LoadSpecSources(sources SpecSources, fetcher *Fetcher, composed bool, options []load.Option, limits Limits) (*Specs, error) {
+
+	base, err := loadInputSources(sources[InputBase], fetcher, options, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := loadInputSources(sources[InputRevision], fetcher, options, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Specs{
+		Base:     base,
+		Revision: revision,
+		Composed: composed || len(base) > 1 || len(revision) > 1,
+	}, nil
+}
+
+func loadInputSources(sources []SpecSource, fetcher *Fetcher, options []load.Option, limits Limits) ([]*load.SpecInfo, error) {
+
+	var res []*load.SpecInfo
+	for _, source := range sources {
+		specs, err := source.Load(fetcher, options...)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, specs...)
+		if len(res) > limits.MaxComposedSpecs {
+			return nil, newTooManySpecsProblem(source.Input(), limits)
+		}
+	}
+
+	return res, nil
+}
+
+// resolveSpecSources resolves the spec sources into specs, with the composed mode and load options of the options
+func resolveSpecSources(sources SpecSources, options *Options, limits Limits, fetcher *Fetcher) (*Specs, error) {
+
+	return LoadSpecSources(sources, fetcher, options.Composed, options.getLoadOptions(), limits)
+}
+
+func hasBody(r *http.Request) bool {
+
+	return r.Body != nil && r.Body != http.NoBody && (r.ContentLength != 0 || r.Header.Get(HeaderContentType) != "")
+}
+
+// readBodySources adds the specs in the request body to sources and the options in it to options, specs which aren't archives or in a JSON request are loaded with loadOptions as they are read
+func readBodySources(r *http.Request, limits Limits, fetcher *Fetcher, loadOptions []load.Option, sources SpecSources, options *bodyOptions) error {
+
+	contentType := r.Header.Get(HeaderContentType)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return NewProblem(http.StatusUnsupportedMediaType, ProblemTypeUnsupportedMediaType, fmt.Sprintf("invalid content type '%s' with %v", contentType, err))
+	}
+
+	switch {
+	case mediaType == HeaderMultipartFormData:
+		return readMultipartSources(r, limits, loadOptions, sources, options)
+	case mediaType == HeaderAppFormUrlEncoded:
+		return readFormSources(r, limits, loadOptions, sources, options)
+	case mediaType == HeaderAppJson:
+		return readJsonSources(r, limits, fetcher, sources, options)
+	case isRawSpecMediaType(mediaType):
+		return readRawSource(r, limits, loadOptions, sources)
+	}
+
+	return NewProblem(http.StatusUnsupportedMediaType, ProblemTypeUnsupportedMediaType,
+		fmt.Sprintf("unsupported content type '%s', use '%s', '%s', '%s' or a single spec as '%s'",
+			contentType, HeaderMultipartFormData, HeaderAppFormUrlEncoded, HeaderAppJson, strings.Join(rawSpecMediaTypes, "', '")))
+}
+
+func isRawSpecMediaType(mediaType string) bool {
+
+	for _, curr := range rawSpecMediaTypes {
+		if mediaType == curr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readMultipartSources reads the parts one at a time without temporary files, streaming each spec to the loader
+func readMultipartSources(r *http.Request, limits Limits, loadOptions []load.Option, sources SpecSources, options *bodyOptions) error {
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return newRequestBodyProblem(fmt.Sprintf("failed to parse '%s' request", HeaderMultipartFormData), err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return newRequestBodyProblem(fmt.Sprintf("failed to read '%s' request part", HeaderMultipartFormData), err)
+		}
+
+		input := part.FormName()
+		if input == InputChecks {
+			if err := readCheckerConfigPart(part, limits.MaxSpecSize, options); err != nil {
+				return err
+			}
+			continue
+		}
+		if isIgnoreInput(input) {
+			data, err := readSpecData(part, input, limits.MaxSpecSize)
+			if err != nil {
+				return err
+			}
+			if err := options.setIgnore(input, data, limits); err != nil {
+				return err
+			}
+			continue
+		}
+		if input != InputBase && input != InputRevision {
+			// other parts are not used, skip them
+			continue
+		}
+
+		if err := addBodySource(part, input, sources.getName(input, part.FileName()), limits, loadOptions, sources); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readFormSources(r *http.Request, limits Limits, loadOptions []load.Option, sources SpecSources, options *bodyOptions) error {
+
+	if err := r.ParseForm(); err != nil {
+		return newRequestBodyProblem(fmt.Sprintf("failed to parse '%s' request", HeaderAppFormUrlEncoded), err)
+	}
+
+	for _, input := range ignoreInputs {
+		if !r.PostForm.Has(input.name) {
+			continue
+		}
+		if err := options.setIgnore(input.name, []byte(r.PostForm.Get(input.name)), limits); err != nil {
+			return err
+		}
+	}
+
+	for _, input := range []string{InputBase, InputRevision} {
+		for _, data := range r.PostForm[input] {
+			if data == "" {
+				continue
+			}
+			if err := addBodySource(strings.NewReader(data), input, sources.getName(input, ""), limits, loadOptions, sources); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readRawSource reads a raw request body, it holds the one spec which was not given as a URI
+func readRawSource(r *http.Request, limits Limits, loadOptions []load.Option, sources SpecSources) error {
+
+	var missing []string
+	for _, input := range []string{InputBase, InputRevision} {
+		if len(sources[input]) == 0 {
+			missing = append(missing, input)
+		}
+	}
+	if len(missing) != 1 {
+		return NewProblem(http.StatusBadRequest, ProblemTypeInvalidRequest,
+			fmt.Sprintf("a raw request body holds a single spec, give exactly one of '%s' and '%s' as a uri", InputBase, InputRevision))
+	}
+
+	return addBodySource(r.Body, missing[0], missing[0], limits, loadOptions, sources)
+}
+
+// addBodySource streams a spec of at most the max spec size from the request body to the loader.
+// Archives are read into memory instead, since they are extracted from a zip's central directory at its end.
+func addBodySource(reader io.Reader, input string, name string, limits Limits, loadOptions []load.Option, sources SpecSources) error {
+
+	buffered := bufio.NewReader(reader)
+	if prefix, _ := buffered.Peek(len(zipMagic)); isArchive(prefix) {
+		data, err := readSpecData(buffered, input, limits.MaxSpecSize)
+		if err != nil {
+			return err
+		}
+		return sources.add(dataSource{input: input, name: name, data: data}, limits)
+	}
+
+	specReader := newSpecReader(buffered, limits.MaxSpecSize)
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = false
+
+	spec, err := load.NewSpecInfo(readerLoader{Loader: loader, reader: specReader}, load.NewSource("-"), loadOptions...)
+	if specReader.err != nil {
+		return newSpecReadProblem(specReader.err, input, limits.MaxSpecSize)
+	}
+	res := streamSource{input: input, name: name, hash: specReader.getHash(), spec: spec}
+	if err != nil {
+		res.err = newSpecLoadProblem(err, input)
+	} else {
+		spec.Url = name
+	}
+
+	return sources.add(res, limits)
+}
+
+// readSpecData reads a spec of at most maxSize bytes
+func readSpecData(reader io.Reader, input string, maxSize int64) ([]byte, error) {
+
+	specReader := newSpecReader(reader, maxSize)
+	data, _ := io.ReadAll(specReader)
+	if specReader.err != nil {
+		return nil, newSpecReadProblem(specReader.err, input, maxSize)
+	}
+
+	return data, nil
+}
+
+// errSpecTooLarge is returned by a specReader when the spec exceeds its max size
+var errSpecTooLarge = errors.New("spec too large")
+
+// specReader reads a spec of at most maxSize bytes from the request body and hashes it on the way
+type specReader struct {
+	reader  io.Reader
+	maxSize int64
+	size    int64
+	hash    hash.Hash
+	err     error // the first error reading the body or errSpecTooLarge, a spec failing to load only because of it is not a problem with the spec
+}
+
+func newSpecReader(reader io.Reader, maxSize int64) *specReader {
+
+	return &specReader{reader: reader, maxSize: maxSize, hash: sha256.New()}
+}
+
+func (r *specReader) Read(p []byte) (int, error) {
+
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	// read one extra byte to detect specs exceeding the limit
+	if remaining := r.maxSize + 1 - r.size; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.size += int64(n)
+	r.hash.Write(p[:n])
+
+	if r.size > r.maxSize {
+		r.err = errSpecTooLarge
+		return n, r.err
+	}
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+
+	return n, err
+}
+
+func (r *specReader) getHash() string {
+
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+func newSpecReadProblem(err error, input string, maxSize int64) *Problem {
+
+	if err == errSpecTooLarge {
+		return NewProblem(http.StatusRequestEntityTooLarge, ProblemTypePayloadTooLarge, fmt.Sprintf("spec exceeds %d bytes", maxSize)).WithInput(input)
+	}
+
+	return newRequestBodyProblem(fmt.Sprintf("failed to read '%s' spec", input), err).WithInput(input)
+}
@@ -0,0 +1,104 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func createRawRequest(t *testing.T, path string, query url.Values, contentType string, body []byte) *http.Request {
+
+	r, err := http.NewRequest(http.MethodPost, path+"?"+query.Encode(), bytes.NewReader(body))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", contentType)
+
+	return r
+}
+
+func TestBreakingChanges_UriAndUploadAreConsistent(t *testing.T) {
+
+	h := createHandler(t)
+
+	r := createMockRequest(t)
+	r.URL.RawQuery = url.Values{"base": {specUri("openapi-test1.yaml")}, "revision": {specUri("openapi-test3.yaml")}}.Encode()
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	fromUri := httptest.NewRecorder()
+	h.BreakingChangesFromUri(fromUri, r)
+
+	r = createMultipartRequest(t, "/breaking-changes", readFile(t, "../data/openapi-test1.yaml"), readFile(t, "../data/openapi-test3.yaml"))
+	r.Header.Set("Accept", internal.HeaderAppJson)
+	fromFile := httptest.NewRecorder()
+	h.BreakingChangesFromFile(fromFile, r)
+
+	require.Equal(t, http.StatusCreated, fromUri.Result().StatusCode)
+	require.Equal(t, fromUri.Result().StatusCode, fromFile.Result().StatusCode)
+	// changes only differ in their source: the URI or the uploaded input
+	require.Equal(t, decodeChangesWithoutSource(t, fromUri), decodeChangesWithoutSource(t, fromFile))
+}
+
+func decodeChangesWithoutSource(t *testing.T, w *httptest.ResponseRecorder) []map[string]any {
+
+	var res struct {
+		Changes []map[string]any `json:"changes"`
+	}
+	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&res))
+	require.NotEmpty(t, res.Changes)
+	for _, change := range res.Changes {
+		delete(change, "source")
+	}
+
+	return res.Changes
+}
+
+func TestChangelogFromFile_RawBody(t *testing.T) {
+
+	r := createRawRequest(t, "/changelog", url.Values{"base": {specUri("openapi-test1.yaml")}},
+		internal.HeaderAppYaml, readFile(t, "../data/openapi-test3.yaml"))
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.NotEmpty(t, w.Body.String())
+}
+
+func TestChangelogFromFile_RawBodyWithoutUri(t *testing.T) {
+
+	r := createRawRequest(t, "/changelog", url.Values{}, internal.HeaderAppYaml, readFile(t, "../data/openapi-test3.yaml"))
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypeInvalidRequest, decodeProblem(t, w).Type)
+}
+
+func TestDiffFromUri_InvalidSpec(t *testing.T) {
+
+	r := createRawRequest(t, "/diff", url.Values{"base": {specUri("openapi-test1.yaml")}}, internal.HeaderAppYaml, []byte("not: [a spec"))
+	w := httptest.NewRecorder()
+
+	createHandler(t).DiffFromUri(w, r)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeSpecLoadFailed, problem.Type)
+	require.Equal(t, internal.InputRevision, problem.Input)
+}
+
+func TestChangelogFromFile_UnsupportedContentType(t *testing.T) {
+
+	r := createRawRequest(t, "/changelog", url.Values{}, "application/xml", []byte("<spec/>"))
+	w := httptest.NewRecorder()
+
+	createHandler(t).ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypeUnsupportedMediaType, decodeProblem(t, w).Type)
+}
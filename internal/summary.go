@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/oasdiff/oasdiff/checker"
+	"github.com/oasdiff/oasdiff/diff"
+	"gopkg.in/yaml.v3"
+)
+
+// Summary counts the differences between the specs per category, and the changes found by the checks per level
+type Summary struct {
+	Diff    bool                                     `json:"diff" yaml:"diff"`
+	Details map[diff.DetailName]*diff.SummaryDetails `json:"details,omitempty" yaml:"details,omitempty"` // categories such as paths, endpoints, schemas, parameters and security
+	Changes ChangesSummary                           `json:"changes" yaml:"changes"`
+}
+
+// ChangesSummary counts the changes found by the checks per level
+type ChangesSummary struct {
+	Errors   int `json:"errors" yaml:"errors"`
+	Warnings int `json:"warnings" yaml:"warnings"`
+	Infos    int `json:"infos" yaml:"infos"`
+}
+
+func newChangesSummary(changes checker.Changes) ChangesSummary {
+
+	counts := changes.GetLevelCount()
+
+	return ChangesSummary{
+		Errors:   counts[checker.ERR],
+		Warnings: counts[checker.WARN],
+		Infos:    counts[checker.INFO],
+	}
+}
+
+func (h *Handler) SummaryFromUri(w http.ResponseWriter, r *http.Request) {
+
+	h.writeReport(w, r, reportSummary)
+}
+
+func (h *Handler) SummaryFromFile(w http.ResponseWriter, r *http.Request) {
+
+	h.writeReport(w, r, reportSummary)
+}
+
+func (h *Handler) writeSummary(w http.ResponseWriter, r *http.Request, specs *Specs, options *Options) {
+
+	contentType, err := negotiateContentType(r, options.Format, summaryMediaTypes)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	summary, err := h.calcSummary(r, specs, options)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	out, err := getSummaryOutput(summary, contentType)
+	if err != nil {
+		writeProblem(w, NewProblem(http.StatusInternalServerError, ProblemTypeRenderFailed, err.Error()))
+		return
+	}
+
+	writeLevelCountHeaders(w, summary.Changes)
+	w.Header().Set(HeaderContentType, contentType)
+	w.Header().Set(HeaderVary, HeaderAccept)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(out)
+}
+
+// calcSummary diffs the specs once and summarizes both the diff and the changes found by the checks
+func (h *Handler) calcSummary(r *http.Request, specs *Specs, options *Options) (*Summary, error) {
+
+	diffReport, operationsSources, err := specs.Diff(options.Diff)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := h.checkChanges(r, diffReport, operationsSources, options, CHANGELOG_LEVEL)
+
+	diffSummary := diffReport.GetSummary()
+
+	return &Summary{
+		Diff:    diffSummary.Diff,
+		Details: diffSummary.Details,
+		Changes: newChangesSummary(changes),
+	}, nil
+}
+
+func getSummaryOutput(summary *Summary, contentType string) ([]byte, error) {
+
+	switch contentType {
+	case HeaderAppJson:
+		out, err := json.Marshal(summary)
+		if err != nil {
+			return nil, fmt.Errorf("failed to json encode summary with '%v'", err)
+		}
+		return out, nil
+	case HeaderAppYaml:
+		out, err := yaml.Marshal(summary)
+		if err != nil {
+			return nil, fmt.Errorf("failed to yaml encode summary with '%v'", err)
+		}
+		return out, nil
+	case HeaderTextPlain:
+		return []byte(getSummaryText(summary)), nil
+	default:
+		return nil, fmt.Errorf("unsupported content type '%v'", contentType)
+	}
+}
+
+// getSummaryText renders the summary as text, one line per category in alphabetical order
+func getSummaryText(summary *Summary) string {
+
+	var res strings.Builder
+	res.WriteString(fmt.Sprintf("changes: %d error, %d warning, %d info\n", summary.Changes.Errors, summary.Changes.Warnings, summary.Changes.Infos))
+
+	names := make([]string, 0, len(summary.Details))
+	for name := range summary.Details {
+		names = append(names, string(name))
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		details := summary.Details[diff.DetailName(name)]
+		res.WriteString(fmt.Sprintf("%s: %d added, %d deleted, %d modified\n", name, details.Added, details.Deleted, details.Modified))
+	}
+
+	return res.String()
+}
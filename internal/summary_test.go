@@ -0,0 +1,40 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummaryFromUri(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	createHandler(t).SummaryFromUri(w, createBreakingChangesUriRequest(t, url.Values{}))
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	var summary internal.Summary
+	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&summary))
+	require.True(t, summary.Diff)
+	require.Equal(t, 2, summary.Changes.Errors)
+	require.Equal(t, 4, summary.Changes.Warnings)
+	require.Equal(t, 4, summary.Details["paths"].Modified)
+	require.Equal(t, "2", w.Result().Header.Get(internal.HeaderErrors))
+}
+
+func TestSummaryFromFile_Text(t *testing.T) {
+
+	r := createMultipartRequest(t, "/summary", readFile(t, "../data/openapi-test1.yaml"), readFile(t, "../data/openapi-test3.yaml"))
+	r.Header.Set("Accept", internal.HeaderTextPlain)
+	w := httptest.NewRecorder()
+
+	createHandler(t).SummaryFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "changes: 2 error, 4 warning,")
+	require.Contains(t, w.Body.String(), "\npaths: ")
+}
@@ -0,0 +1,515 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/ds"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff/checker"
+	"github.com/oasdiff/oasdiff/load"
+)
+
+const (
+	KindTemplate ds.Kind = "template"
+
+	// ParamTemplate renders the changelog with a template of the tenant instead of a built-in format
+	ParamTemplate = "template"
+	// PathParamTemplateName is the name of a template in the templates path
+	PathParamTemplateName = "template-name"
+)
+
+// the sandbox of tenant templates: templates and their output are bounded, ranges can't be nested deeper than maxTemplateRangeDepth
+// and numbers are small, range iterations are counted against maxTemplateSteps and execution stops at the timeout, see templateBudget
+const (
+	maxTemplateSize       = 64 << 10
+	maxTemplateOutputSize = 10 << 20
+	maxTemplateRangeDepth = 2
+	maxTemplateNumber     = 1000
+	maxTemplateSteps      = 1000000
+	templateTimeout       = 10 * time.Second
+)
+
+// templateStepFunc is inserted at the start of every range of a template to count its iterations, templates may not call it themselves
+const templateStepFunc = "sandboxStep"
+
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// templateMediaTypes are the media types of templates, HTML templates are parsed with html/template so texts from the specs are escaped
+var templateMediaTypes = []string{HeaderTextMarkdown, HeaderTextHtml, HeaderTextPlain}
+
+// templateBuiltins are the text/template builtin functions templates may use, 'call' is left out so templates only run the functions of the allowlist
+var templateBuiltins = []string{"and", "or", "not", "eq", "ne", "lt", "le", "gt", "ge", "len", "index", "slice", "print", "printf", "println", "html", "js", "urlquery"}
+
+// Template is a changelog template uploaded by a tenant
+type Template struct {
+	Id        string `datastore:"id" json:"id"` // the tenant id and the name
+	Name      string `datastore:"name" json:"name"`
+	TenantId  string `datastore:"tenant_id" json:"tenant_id"`
+	MediaType string `datastore:"media_type" json:"media_type"`
+	Body      string `datastore:"body,noindex" json:"-"`
+	Created   int64  `datastore:"created" json:"created"`
+	Updated   int64  `datastore:"updated" json:"updated"`
+}
+
+// TemplateData is the data templates are executed with
+type TemplateData struct {
+	Changes         checker.Changes
+	BaseVersion     string
+	RevisionVersion string
+	Localizer       checker.Localizer
+}
+
+// ChangesGroup is a group of changes sharing a key, see the groupBy template function
+type ChangesGroup struct {
+	Key     string
+	Changes checker.Changes
+}
+
+func getTemplateId(tenantId string, name string) string {
+
+	return tenantId + "/" + name
+}
+
+func (h *Handler) PutTemplate(w http.ResponseWriter, r *http.Request) {
+
+	tenantId, name := mux.Vars(r)[tenant.PathParamTenantId], mux.Vars(r)[PathParamTemplateName]
+	if !templateNamePattern.MatchString(name) {
+		writeProblem(w, NewProblem(http.StatusBadRequest, ProblemTypeInvalidParameter,
+			fmt.Sprintf("invalid template name '%s', use up to 64 letters, digits, '-' and '_'", name)).WithInput(PathParamTemplateName))
+		return
+	}
+
+	mediaType, err := getTemplateMediaType(r)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTemplateSize))
+	if err != nil {
+		writeProblem(w, newRequestBodyProblem("failed to read template", err))
+		return
+	}
+
+	if _, err := parseTemplate(name, mediaType, string(body), checker.NewDefaultLocalizer(), newTemplateBudget()); err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	now := time.Now().Unix()
+	res := Template{Id: getTemplateId(tenantId, name), Name: name, TenantId: tenantId, MediaType: mediaType, Body: string(body), Created: now, Updated: now}
+
+	status := http.StatusCreated
+	var existing Template
+	if err := h.dsc.Get(KindTemplate, res.Id, &existing); err == nil && existing.Created != 0 {
+		res.Created = existing.Created
+		status = http.StatusOK
+	}
+
+	if err := h.dsc.Put(KindTemplate, res.Id, &res); err != nil {
+		writeProblem(w, newServerProblem(fmt.Sprintf("failed to store template '%s'", name)))
+		return
+	}
+
+	out, err := json.Marshal(res)
+	if err != nil {
+		writeProblem(w, newServerProblem(fmt.Sprintf("failed to json encode template with '%v'", err)))
+		return
+	}
+
+	w.Header().Set(HeaderContentType, HeaderAppJson)
+	w.WriteHeader(status)
+	_, _ = w.Write(out)
+}
+
+func (h *Handler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+
+	res, err := h.getTemplate(mux.Vars(r)[tenant.PathParamTenantId], mux.Vars(r)[PathParamTemplateName], PathParamTemplateName)
+	if err != nil {
+		writeProblem(w, err)
+		return
+	}
+
+	// a template is tenant content, it is downloaded rather than displayed so that an HTML template can't run in the service's origin
+	w.Header().Set(HeaderContentType, res.MediaType)
+	w.Header().Set(HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": res.Name}))
+	w.Header().Set(HeaderContentTypeOptions, "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(res.Body))
+}
+
+// getTemplateMediaType returns the media type of an uploaded template, text/plain if none was given
+func getTemplateMediaType(r *http.Request) (string, error) {
+
+	contentType := r.Header.Get(HeaderContentType)
+	if contentType == "" {
+		return HeaderTextPlain, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !slices.Contains(templateMediaTypes, mediaType) {
+		return "", NewProblem(http.StatusUnsupportedMediaType, ProblemTypeUnsupportedMediaType,
+			fmt.Sprintf("unsupported template media type '%s', use '%s'", contentType, strings.Join(templateMediaTypes, "', '")))
+	}
+
+	return mediaType, nil
+}
+
+// getRequestTemplate returns the tenant's template named by the template option, or nil if it was not given
+func (h *Handler) getRequestTemplate(r *http.Request, options *Options) (*Template, error) {
+
+	if options.Template == "" {
+		return nil, nil
+	}
+
+	return h.getTemplate(mux.Vars(r)[tenant.PathParamTenantId], options.Template, ParamTemplate)
+}
+
+func (h *Handler) getTemplate(tenantId string, name string, input string) (*Template, error) {
+
+	notFound := NewProblem(http.StatusNotFound, ProblemTypeTemplateNotFound, fmt.Sprintf("template '%s' not found", name)).WithInput(input)
+	if tenantId == "" || !templateNamePattern.MatchString(name) {
+		return nil, notFound
+	}
+
+	var res Template
+	if err := h.dsc.Get(KindTemplate, getTemplateId(tenantId, name), &res); err != nil {
+		if ds.IsNoSuchEntityError(err) {
+			return nil, notFound
+		}
+		return nil, newServerProblem(fmt.Sprintf("failed to get template '%s'", name))
+	}
+	if res.Body == "" {
+		return nil, notFound
+	}
+
+	return &res, nil
+}
+
+// render executes the template with the changes in the sandbox, execution fails once the template's budget is used up
+func (t *Template) render(changes checker.Changes, specInfoPair *load.SpecInfoPair, languageCode string) ([]byte, error) {
+
+	localizer := checker.NewLocalizer(languageCode)
+	budget := newTemplateBudget()
+	executable, err := parseTemplate(t.Name, t.MediaType, t.Body, localizer, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	data := TemplateData{
+		Changes:         changes,
+		BaseVersion:     specInfoPair.GetBaseVersion(),
+		RevisionVersion: specInfoPair.GetRevisionVersion(),
+		Localizer:       localizer,
+	}
+
+	var out bytes.Buffer
+	if err := executable.Execute(&limitedWriter{w: &out, remaining: maxTemplateOutputSize, budget: budget}, data); err != nil {
+		return nil, NewProblem(http.StatusUnprocessableEntity, ProblemTypeInvalidTemplate, fmt.Sprintf("failed to execute template '%s' with %v", t.Name, err)).WithInput(ParamTemplate)
+	}
+
+	return out.Bytes(), nil
+}
+
+// templateBudget bounds the execution of a template, the writes and range iterations of a template check it and fail once it is used up, which aborts the execution
+type templateBudget struct {
+	deadline time.Time
+	steps    int // range iterations left
+}
+
+func newTemplateBudget() *templateBudget {
+
+	return &templateBudget{deadline: time.Now().Add(templateTimeout), steps: maxTemplateSteps}
+}
+
+// check fails once the deadline passed
+func (b *templateBudget) check() error {
+
+	if time.Now().After(b.deadline) {
+		return fmt.Errorf("timed out after %v", templateTimeout)
+	}
+
+	return nil
+}
+
+// step counts a range iteration, it is the template function templateStepFunc
+func (b *templateBudget) step() (string, error) {
+
+	if b.steps--; b.steps < 0 {
+		return "", fmt.Errorf("ranges exceed %d iterations", maxTemplateSteps)
+	}
+
+	return "", b.check()
+}
+
+type executableTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+// parseTemplate parses the template with the allowlisted functions and checks that it stays in the sandbox, its range iterations are counted against the budget
+func parseTemplate(name string, mediaType string, body string, localizer checker.Localizer, budget *templateBudget) (executableTemplate, error) {
+
+	funcs := getTemplateFuncs(localizer)
+	funcs[templateStepFunc] = budget.step
+
+	var res executableTemplate
+	var trees []*parse.Tree
+	var err error
+	if mediaType == HeaderTextHtml {
+		var t *htmltemplate.Template
+		if t, err = htmltemplate.New(name).Funcs(htmltemplate.FuncMap(funcs)).Parse(body); err == nil {
+			for _, curr := range t.Templates() {
+				trees = append(trees, curr.Tree)
+			}
+			res = t
+		}
+	} else {
+		var t *template.Template
+		if t, err = template.New(name).Funcs(funcs).Parse(body); err == nil {
+			for _, curr := range t.Templates() {
+				trees = append(trees, curr.Tree)
+			}
+			res = t
+		}
+	}
+	if err != nil {
+		return nil, newInvalidTemplateProblem(name, err)
+	}
+
+	if len(trees) != 1 {
+		return nil, newInvalidTemplateProblem(name, errors.New("templates may not define templates"))
+	}
+	if err := checkTemplateNode(trees[0].Root, 0); err != nil {
+		return nil, newInvalidTemplateProblem(name, err)
+	}
+
+	step, err := parse.Parse(templateStepFunc, "{{"+templateStepFunc+"}}", "", "", funcs)
+	if err != nil {
+		return nil, newServerProblem(fmt.Sprintf("failed to parse template step with %v", err))
+	}
+	addTemplateSteps(trees[0].Root, step[templateStepFunc].Root.Nodes[0])
+
+	return res, nil
+}
+
+// addTemplateSteps inserts a copy of the step action at the start of every range of the list, so that every iteration counts against the budget
+func addTemplateSteps(list *parse.ListNode, step parse.Node) {
+
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			addTemplateSteps(n.List, step)
+			addTemplateSteps(n.ElseList, step)
+		case *parse.WithNode:
+			addTemplateSteps(n.List, step)
+			addTemplateSteps(n.ElseList, step)
+		case *parse.RangeNode:
+			addTemplateSteps(n.List, step)
+			addTemplateSteps(n.ElseList, step)
+			if n.List != nil {
+				n.List.Nodes = append([]parse.Node{step.Copy()}, n.List.Nodes...)
+			}
+		}
+	}
+}
+
+func newInvalidTemplateProblem(name string, err error) *Problem {
+
+	return NewProblem(http.StatusBadRequest, ProblemTypeInvalidTemplate, fmt.Sprintf("invalid template '%s' with %v", name, err))
+}
+
+// checkTemplateNode returns an error if the node or its children leave the sandbox
+func checkTemplateNode(node parse.Node, rangeDepth int) error {
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, curr := range n.Nodes {
+			if err := checkTemplateNode(curr, rangeDepth); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkTemplateNode(n.Pipe, rangeDepth)
+	case *parse.IfNode:
+		return checkTemplateBranch(&n.BranchNode, rangeDepth)
+	case *parse.WithNode:
+		return checkTemplateBranch(&n.BranchNode, rangeDepth)
+	case *parse.RangeNode:
+		if rangeDepth == maxTemplateRangeDepth {
+			return fmt.Errorf("ranges may be nested at most %d deep", maxTemplateRangeDepth)
+		}
+		return checkTemplateBranch(&n.BranchNode, rangeDepth+1)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, curr := range n.Cmds {
+			if err := checkTemplateNode(curr, rangeDepth); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, curr := range n.Args {
+			if err := checkTemplateNode(curr, rangeDepth); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return checkTemplateNode(n.Node, rangeDepth)
+	case *parse.IdentifierNode:
+		if !slices.Contains(templateBuiltins, n.Ident) {
+			if _, ok := getTemplateFuncs(nil)[n.Ident]; !ok {
+				return fmt.Errorf("function '%s' is not allowed", n.Ident)
+			}
+		}
+	case *parse.NumberNode:
+		if !n.IsInt || n.Int64 < -maxTemplateNumber || n.Int64 > maxTemplateNumber {
+			return fmt.Errorf("number '%s' is out of range, use integers from -%d to %d", n.Text, maxTemplateNumber, maxTemplateNumber)
+		}
+	case *parse.TemplateNode:
+		return errors.New("templates may not execute templates")
+	}
+
+	return nil
+}
+
+func checkTemplateBranch(n *parse.BranchNode, rangeDepth int) error {
+
+	for _, curr := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := checkTemplateNode(curr, rangeDepth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getTemplateFuncs returns the allowlist of functions which templates may use besides the builtins
+func getTemplateFuncs(localizer checker.Localizer) template.FuncMap {
+
+	return template.FuncMap{
+		"text": func(change checker.Change) string {
+			return change.GetUncolorizedText(localizer)
+		},
+		"comment": func(change checker.Change) string {
+			return change.GetComment(localizer)
+		},
+		"level": func(change checker.Change) string {
+			return change.GetLevel().String()
+		},
+		"localize": func(id string, args ...any) string {
+			return localizer(id, args...)
+		},
+		"filterLevel": filterChangesByLevel,
+		"groupBy":     groupChanges,
+		"upper":       strings.ToUpper,
+		"lower":       strings.ToLower,
+		"trim":        strings.TrimSpace,
+		"replace":     strings.ReplaceAll,
+		"join":        strings.Join,
+		"contains":    strings.Contains,
+		"hasPrefix":   strings.HasPrefix,
+		"hasSuffix":   strings.HasSuffix,
+	}
+}
+
+// filterChangesByLevel returns the changes of a level: error, warning or info
+func filterChangesByLevel(level string, changes checker.Changes) (checker.Changes, error) {
+
+	if !slices.Contains([]string{"error", "warning", "info"}, level) {
+		return nil, fmt.Errorf("invalid level '%s', use error, warning or info", level)
+	}
+
+	res := checker.Changes{}
+	for _, change := range changes {
+		if change.GetLevel().String() == level {
+			res = append(res, change)
+		}
+	}
+
+	return res, nil
+}
+
+// groupChanges groups the changes by their path, operation, endpoint, level, section or check id.
+// Groups are sorted by their key, levels from error to info.
+func groupChanges(key string, changes checker.Changes) ([]ChangesGroup, error) {
+
+	getKey := map[string]func(checker.Change) string{
+		"path":      checker.Change.GetPath,
+		"operation": checker.Change.GetOperation,
+		"endpoint":  getChangeLocation,
+		"level":     func(change checker.Change) string { return strconv.Itoa(int(checker.ERR - change.GetLevel())) },
+		"section":   checker.Change.GetSection,
+		"id":        checker.Change.GetId,
+	}[key]
+	if getKey == nil {
+		return nil, fmt.Errorf("invalid group key '%s', use path, operation, endpoint, level, section or id", key)
+	}
+
+	var res []ChangesGroup
+	indexes := map[string]int{}
+	for _, change := range changes {
+		groupKey := getKey(change)
+		index, ok := indexes[groupKey]
+		if !ok {
+			index = len(res)
+			indexes[groupKey] = index
+			res = append(res, ChangesGroup{Key: groupKey})
+		}
+		res[index].Changes = append(res[index].Changes, change)
+	}
+
+	slices.SortFunc(res, func(a, b ChangesGroup) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	if key == "level" {
+		for i := range res {
+			res[i].Key = res[i].Changes[0].GetLevel().String()
+		}
+	}
+
+	return res, nil
+}
+
+// limitedWriter fails writes beyond its remaining bytes or once the budget's deadline passed
+type limitedWriter struct {
+	w         io.Writer
+	remaining int
+	budget    *templateBudget
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+
+	if err := l.budget.check(); err != nil {
+		return 0, err
+	}
+	if len(p) > l.remaining {
+		return 0, fmt.Errorf("output exceeds %d bytes", maxTemplateOutputSize)
+	}
+	l.remaining -= len(p)
+
+	return l.w.Write(p)
+}
@@ -0,0 +1,204 @@
+package internal_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/ds"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+// templateClient is a datastore client keeping the templates which were put
+type templateClient struct {
+	ds.Client
+	templates map[string]internal.Template
+}
+
+func (c templateClient) Get(kind ds.Kind, id string, dst interface{}) error {
+
+	if kind == internal.KindTemplate {
+		res, ok := c.templates[id]
+		if !ok {
+			return errors.New("datastore: no such entity")
+		}
+		*dst.(*internal.Template) = res
+	}
+
+	return nil
+}
+
+func (c templateClient) Put(kind ds.Kind, id string, src interface{}) error {
+
+	c.templates[id] = *src.(*internal.Template)
+
+	return nil
+}
+
+func createTemplateHandler() *internal.Handler {
+
+	return internal.NewHandler(templateClient{templates: map[string]internal.Template{}}, nil, internal.NewLimits(), createFetcher(), nil)
+}
+
+func putTemplate(t *testing.T, h *internal.Handler, name string, contentType string, body string) *httptest.ResponseRecorder {
+
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPut, "/tenants/test-tenant/templates/"+name, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant", internal.PathParamTemplateName: name})
+	w := httptest.NewRecorder()
+
+	h.PutTemplate(w, r)
+
+	return w
+}
+
+func getChangelogWithTemplate(t *testing.T, h *internal.Handler, name string) *httptest.ResponseRecorder {
+
+	t.Helper()
+
+	r := createBreakingChangesUriRequest(t, url.Values{"template": {name}})
+	r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant"})
+	w := httptest.NewRecorder()
+
+	h.ChangelogFromUri(w, r)
+
+	return w
+}
+
+func TestChangelogFromUri_Template(t *testing.T) {
+
+	const notes = `# Release notes {{.BaseVersion}} to {{.RevisionVersion}}
+{{range groupBy "level" .Changes}}
+## {{upper .Key}}
+{{range .Changes}}- [{{.GetId}}] {{.GetOperation}} {{.GetPath}} {{text .}}
+{{end}}{{end}}`
+
+	h := createTemplateHandler()
+
+	w := putTemplate(t, h, "notes", "text/markdown; charset=utf-8", notes)
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode, w.Body.String())
+	require.Equal(t, http.StatusOK, putTemplate(t, h, "notes", internal.HeaderTextMarkdown, notes).Result().StatusCode)
+
+	w = getChangelogWithTemplate(t, h, "notes")
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode, w.Body.String())
+	require.Equal(t, internal.HeaderTextMarkdown, w.Result().Header.Get("Content-Type"))
+	require.True(t, strings.HasPrefix(w.Body.String(), "# Release notes "))
+	require.Contains(t, w.Body.String(), "## ERROR\n- [response-success-status-removed] GET /api/{domain}/{project}/badges/security-score removed the success response with the status '200'\n")
+	require.Less(t, strings.Index(w.Body.String(), "## ERROR"), strings.Index(w.Body.String(), "## WARNING"))
+	require.Less(t, strings.Index(w.Body.String(), "## WARNING"), strings.Index(w.Body.String(), "## INFO"))
+}
+
+func TestChangelogFromUri_HtmlTemplateEscapes(t *testing.T) {
+
+	h := createTemplateHandler()
+	require.Equal(t, http.StatusCreated, putTemplate(t, h, "page", internal.HeaderTextHtml, `<p>{{"<script>"}} {{len .Changes}}</p>`).Result().StatusCode)
+
+	w := getChangelogWithTemplate(t, h, "page")
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode, w.Body.String())
+	require.Equal(t, internal.HeaderTextHtml, w.Result().Header.Get("Content-Type"))
+	require.Regexp(t, `^<p>&lt;script&gt; \d+</p>$`, w.Body.String())
+}
+
+func TestChangelogFromUri_HtmlTemplateRange(t *testing.T) {
+
+	h := createTemplateHandler()
+	require.Equal(t, http.StatusCreated, putTemplate(t, h, "page", internal.HeaderTextHtml, `<ul>{{range .Changes}}<li>{{.GetId}}</li>{{end}}</ul>`).Result().StatusCode)
+
+	w := getChangelogWithTemplate(t, h, "page")
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode, w.Body.String())
+	require.Contains(t, w.Body.String(), "<li>response-success-status-removed</li>")
+}
+
+func TestChangelogFromUri_TemplateStepsExceeded(t *testing.T) {
+
+	h := createTemplateHandler()
+	require.Equal(t, http.StatusCreated, putTemplate(t, h, "loop", internal.HeaderTextPlain, `{{range 1000}}{{range 1000}}{{end}}{{end}}`).Result().StatusCode)
+
+	w := getChangelogWithTemplate(t, h, "loop")
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	problem := decodeProblem(t, w)
+	require.Equal(t, internal.ProblemTypeInvalidTemplate, problem.Type)
+	require.Contains(t, problem.Detail, "ranges exceed 1000000 iterations")
+}
+
+func TestPutTemplate_Sandbox(t *testing.T) {
+
+	for name, body := range map[string]string{
+		"call":      `{{call .Localizer "x"}}`,
+		"define":    `{{define "x"}}{{end}}`,
+		"template":  `{{template "sandbox"}}`,
+		"depth":     `{{range .Changes}}{{range $.Changes}}{{range $.Changes}}{{end}}{{end}}{{end}}`,
+		"number":    `{{range 100000000}}{{end}}`,
+		"undefined": `{{exec "ls"}}`,
+		"step":      `{{sandboxStep}}`,
+	} {
+		w := putTemplate(t, createTemplateHandler(), "sandbox", internal.HeaderTextPlain, body)
+
+		require.Equal(t, http.StatusBadRequest, w.Result().StatusCode, name)
+		require.Equal(t, internal.ProblemTypeInvalidTemplate, decodeProblem(t, w).Type, name)
+	}
+}
+
+func TestPutTemplate_UnsupportedMediaType(t *testing.T) {
+
+	w := putTemplate(t, createTemplateHandler(), "notes", internal.HeaderAppJson, `{}`)
+
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Result().StatusCode)
+}
+
+func TestPutTemplate_InvalidName(t *testing.T) {
+
+	w := putTemplate(t, createTemplateHandler(), "a.b", internal.HeaderTextPlain, `{{len .Changes}}`)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Equal(t, internal.PathParamTemplateName, decodeProblem(t, w).Input)
+}
+
+func TestChangelogFromUri_TemplateNotFound(t *testing.T) {
+
+	w := getChangelogWithTemplate(t, createTemplateHandler(), "missing")
+
+	require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	require.Equal(t, internal.ParamTemplate, decodeProblem(t, w).Input)
+}
+
+func TestChangelogFromUri_TemplateExecutionFails(t *testing.T) {
+
+	h := createTemplateHandler()
+	require.Equal(t, http.StatusCreated, putTemplate(t, h, "bad", internal.HeaderTextPlain, `{{range groupBy "color" .Changes}}{{end}}`).Result().StatusCode)
+
+	w := getChangelogWithTemplate(t, h, "bad")
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	require.Equal(t, internal.ProblemTypeInvalidTemplate, decodeProblem(t, w).Type)
+}
+
+func TestGetTemplate(t *testing.T) {
+
+	h := createTemplateHandler()
+	require.Equal(t, http.StatusCreated, putTemplate(t, h, "notes", internal.HeaderTextMarkdown, `{{len .Changes}} changes`).Result().StatusCode)
+
+	r := httptest.NewRequest(http.MethodGet, "/tenants/test-tenant/templates/notes", nil)
+	r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant", internal.PathParamTemplateName: "notes"})
+	w := httptest.NewRecorder()
+
+	h.GetTemplate(w, r)
+
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Equal(t, internal.HeaderTextMarkdown, w.Result().Header.Get("Content-Type"))
+	require.Equal(t, `attachment; filename=notes`, w.Result().Header.Get("Content-Disposition"))
+	require.Equal(t, "nosniff", w.Result().Header.Get("X-Content-Type-Options"))
+	require.Equal(t, `{{len .Changes}} changes`, w.Body.String())
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/ds"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff/checker/localizations"
+	log "github.com/sirupsen/logrus"
+)
+
+const KindTenantSettings ds.Kind = "tenant_settings"
+
+// TenantSettings holds per-tenant overrides of the deployment defaults, zero values mean no override
+type TenantSettings struct {
+	Id                 string `datastore:"id" json:"id"`
+	MaxRequestBodySize int64  `datastore:"max_request_body_size" json:"max_request_body_size"`
+	MaxSpecSize        int64  `datastore:"max_spec_size" json:"max_spec_size"`
+	MaxArchiveSize     int64  `datastore:"max_archive_size" json:"max_archive_size"`
+	MaxArchiveFiles    int    `datastore:"max_archive_files" json:"max_archive_files"`
+	MaxComposedSpecs   int    `datastore:"max_composed_specs" json:"max_composed_specs"`
+
+	// default deprecation policy of the tenant's requests
+	DeprecationDaysBeta   int `datastore:"deprecation_days_beta" json:"deprecation_days_beta"`
+	DeprecationDaysStable int `datastore:"deprecation_days_stable" json:"deprecation_days_stable"`
+
+	// default language of the tenant's reports, when the request accepts no supported language
+	Language string `datastore:"language" json:"language"`
+}
+
+// getDeprecationDays returns the tenant's default deprecation policy, negative days are ignored
+func (s *TenantSettings) getDeprecationDays() DeprecationDays {
+
+	return DeprecationDays{
+		Beta:   uint(max(s.DeprecationDaysBeta, 0)),
+		Stable: uint(max(s.DeprecationDaysStable, 0)),
+	}
+}
+
+// tenantSettingsKey keeps the tenant's settings in the request's context
+type tenantSettingsKey struct{}
+
+// withTenantSettings reads the tenant's settings once and keeps them in the request's context,
+// so that a request makes a single datastore read and all of its parts apply the same settings
+func (h *Handler) withTenantSettings(r *http.Request) *http.Request {
+
+	if _, ok := r.Context().Value(tenantSettingsKey{}).(*TenantSettings); ok {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), tenantSettingsKey{}, h.readTenantSettings(r)))
+}
+
+// getTenantSettings returns the settings kept in the request's context by withTenantSettings, or else reads them
+func (h *Handler) getTenantSettings(r *http.Request) *TenantSettings {
+
+	if res, ok := r.Context().Value(tenantSettingsKey{}).(*TenantSettings); ok {
+		return res
+	}
+
+	return h.readTenantSettings(r)
+}
+
+func (h *Handler) readTenantSettings(r *http.Request) *TenantSettings {
+
+	var res TenantSettings
+
+	id := mux.Vars(r)[tenant.PathParamTenantId]
+	if id == "" {
+		return &res
+	}
+
+	if err := h.dsc.Get(KindTenantSettings, id, &res); err != nil {
+		if !ds.IsNoSuchEntityError(err) {
+			log.Warnf("failed to get settings of tenant '%s', using defaults with %v", id, err)
+		}
+		return &TenantSettings{}
+	}
+	res.validate(id)
+
+	return &res
+}
+
+// validate drops settings which can't be applied, with a warning since the settings are stored outside of the service
+func (s *TenantSettings) validate(id string) {
+
+	if s.Language == "" {
+		return
+	}
+
+	language, ok := matchLanguage(strings.ToLower(s.Language), localizations.GetSupportedLanguages())
+	if !ok {
+		log.Warnf("unsupported language '%s' in settings of tenant '%s', using the request's language or else English", s.Language, id)
+	}
+	s.Language = language
+}
@@ -0,0 +1,48 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/go-common/ds"
+	"github.com/oasdiff/go-common/tenant"
+	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/stretchr/testify/require"
+)
+
+// countingClient is a datastore client counting the reads of tenant settings
+type countingClient struct {
+	settingsClient
+	reads *atomic.Int32
+}
+
+func (c countingClient) Get(kind ds.Kind, id string, dst interface{}) error {
+
+	if kind == internal.KindTenantSettings {
+		c.reads.Add(1)
+	}
+
+	return c.settingsClient.Get(kind, id, dst)
+}
+
+func TestChangelogFromFile_TenantSettingsReadOnce(t *testing.T) {
+
+	reads := &atomic.Int32{}
+	cache, err := internal.NewCache(internal.CacheConfig{MemorySize: 1 << 20})
+	require.NoError(t, err)
+	h := internal.NewHandler(countingClient{settingsClient: settingsClient{settings: internal.TenantSettings{Language: "es"}}, reads: reads}, nil, internal.NewLimits(), createFetcher(), cache)
+
+	r := createChangelogFileRequest(t, "../data/openapi-test3.yaml")
+	r.URL.RawQuery = url.Values{"deprecation-days-beta": {"30"}}.Encode()
+	r = mux.SetURLVars(r, map[string]string{tenant.PathParamTenantId: "test-tenant"})
+	w := httptest.NewRecorder()
+	h.ChangelogFromFile(w, r)
+
+	require.Equal(t, http.StatusCreated, w.Result().StatusCode, w.Body.String())
+	require.Equal(t, "es", w.Result().Header.Get(internal.HeaderContentLanguage))
+	require.Equal(t, int32(1), reads.Load())
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/oasdiff/go-common/env"
 	"github.com/oasdiff/go-common/tenant"
 	"github.com/oasdiff/oasdiff-service/internal"
+	"github.com/oasdiff/oasdiff-service/internal/badge"
 	"github.com/onrik/logrus/filename"
 	log "github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/writer"
@@ -25,11 +26,27 @@ func main() {
 		diff            = fmt.Sprintf("/tenants/{%s}/diff", tenant.PathParamTenantId)
 		breakingChanges = fmt.Sprintf("/tenants/{%s}/breaking-changes", tenant.PathParamTenantId)
 		changelog       = fmt.Sprintf("/tenants/{%s}/changelog", tenant.PathParamTenantId)
-
-		v = tenant.NewValidator(ds.NewClient(env.GetGCPProject(), env.GetGCPDatastoreNamespace()))
-		h = internal.NewHandler()
+		summary         = fmt.Sprintf("/tenants/{%s}/summary", tenant.PathParamTenantId)
+		badgePath       = fmt.Sprintf("/tenants/{%s}/badge", tenant.PathParamTenantId)
+		templatePath    = fmt.Sprintf("/tenants/{%s}/templates/{%s}", tenant.PathParamTenantId, internal.PathParamTemplateName)
+		jobsPath        = fmt.Sprintf("/tenants/{%s}/jobs", tenant.PathParamTenantId)
+		jobPath         = fmt.Sprintf("/tenants/{%s}/jobs/{%s}", tenant.PathParamTenantId, internal.PathParamJobId)
+
+		dsc = ds.NewClient(env.GetGCPProject(), env.GetGCPDatastoreNamespace())
+		v   = tenant.NewValidator(dsc)
 	)
 
+	bg, err := newBadgeGenerator()
+	if err != nil {
+		log.Fatalf("failed to create badge generator with %v", err)
+	}
+	cache, err := internal.NewCache(internal.NewCacheConfig())
+	if err != nil {
+		log.Fatalf("failed to create cache with %v", err)
+	}
+	h := internal.NewHandler(dsc, bg, internal.NewLimits(), internal.NewFetcher(internal.NewFetcherConfig()), cache)
+	jobs := internal.NewJobs(h, internal.NewJobsConfig())
+
 	serve(
 		[]string{
 			fmt.Sprintf("/tenants/{%s}/docs.html", tenant.PathParamTenantId),
@@ -37,6 +54,11 @@ func main() {
 			diff, diff, diff,
 			breakingChanges, breakingChanges, breakingChanges,
 			changelog, changelog, changelog,
+			summary, summary, summary,
+			badgePath, badgePath,
+			templatePath, templatePath, templatePath,
+			jobsPath, jobsPath,
+			jobPath, jobPath,
 		},
 		[]string{
 			http.MethodGet,
@@ -44,6 +66,11 @@ func main() {
 			http.MethodPost, http.MethodGet, http.MethodOptions,
 			http.MethodPost, http.MethodGet, http.MethodOptions,
 			http.MethodPost, http.MethodGet, http.MethodOptions,
+			http.MethodPost, http.MethodGet, http.MethodOptions,
+			http.MethodGet, http.MethodOptions,
+			http.MethodPut, http.MethodGet, http.MethodOptions,
+			http.MethodPost, http.MethodOptions,
+			http.MethodGet, http.MethodOptions,
 		},
 		[]func(http.ResponseWriter, *http.Request){
 			func(w http.ResponseWriter, r *http.Request) { http.ServeFile(w, r, "/app/docs/docs.html") },
@@ -51,11 +78,26 @@ func main() {
 			access(h.DiffFromFile), access(h.DiffFromUri), options([]string{http.MethodPost, http.MethodGet}),
 			access(h.BreakingChangesFromFile), access(h.BreakingChangesFromUri), options([]string{http.MethodPost, http.MethodGet}),
 			access(h.ChangelogFromFile), access(h.ChangelogFromUri), options([]string{http.MethodPost, http.MethodGet}),
+			access(h.SummaryFromFile), access(h.SummaryFromUri), options([]string{http.MethodPost, http.MethodGet}),
+			access(h.BadgeFromUri), options([]string{http.MethodGet}),
+			access(h.PutTemplate), access(h.GetTemplate), options([]string{http.MethodPut, http.MethodGet}),
+			access(jobs.Create), options([]string{http.MethodPost}),
+			access(jobs.Get), options([]string{http.MethodGet}),
 		},
 		v.Validate,
 	)
 }
 
+// newBadgeGenerator loads the TTF font file given by BADGE_FONT, or else the font embedded in the binary
+func newBadgeGenerator() (*badge.Generator, error) {
+
+	if fontFile := env.GetWithDefault("BADGE_FONT", ""); fontFile != "" {
+		return badge.NewGenerator(fontFile, 11)
+	}
+
+	return badge.NewDefaultGenerator(11)
+}
+
 func access(next func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
 
 	return func(w http.ResponseWriter, r *http.Request) {